@@ -0,0 +1,220 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORSConfig describes which cross-origin requests are allowed. Origins are
+// exact, such as "https://shop.example.com", wildcard subdomains, such as
+// "https://*.example.com", or "*" for any origin.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Validate rejects configurations browsers would refuse or that would let
+// any site make credentialed requests
+func (c CORSConfig) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("credentials cannot be allowed for any origin")
+			}
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#") {
+			return fmt.Errorf("invalid origin %q", origin)
+		}
+		if strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			return fmt.Errorf("invalid origin %q: only a leading *. is allowed", origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("max age must not be negative")
+	}
+	return nil
+}
+
+// CORSConfigFromEnv overrides defaults with CORS_ALLOWED_ORIGINS,
+// CORS_ALLOWED_HEADERS and CORS_EXPOSED_HEADERS (comma-separated lists),
+// CORS_ALLOW_CREDENTIALS (a boolean) and CORS_MAX_AGE (a duration such as
+// "10m")
+func CORSConfigFromEnv(defaults CORSConfig) (CORSConfig, error) {
+	config := defaults
+	if value, ok := os.LookupEnv("CORS_ALLOWED_ORIGINS"); ok {
+		config.AllowedOrigins = splitList(value)
+	}
+	if value, ok := os.LookupEnv("CORS_ALLOWED_HEADERS"); ok {
+		config.AllowedHeaders = splitList(value)
+	}
+	if value, ok := os.LookupEnv("CORS_EXPOSED_HEADERS"); ok {
+		config.ExposedHeaders = splitList(value)
+	}
+	if value := os.Getenv("CORS_ALLOW_CREDENTIALS"); value != "" {
+		allow, err := strconv.ParseBool(value)
+		if err != nil {
+			return config, fmt.Errorf("CORS_ALLOW_CREDENTIALS: %w", err)
+		}
+		config.AllowCredentials = allow
+	}
+	if value := os.Getenv("CORS_MAX_AGE"); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("CORS_MAX_AGE: %w", err)
+		}
+		config.MaxAge = maxAge
+	}
+	return config, config.Validate()
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// CORSMiddleware applies config to every request served by next. Preflight
+// requests are answered with the methods routes registers for the path: 404
+// when no route matches it and 405 when the requested method is not among
+// them. It must wrap the router rather than be added with Use, as the router
+// runs middleware only for requests that match a route's method too.
+func CORSMiddleware(config CORSConfig, routes *mux.Router) func(http.Handler) http.Handler {
+	allowedHeaders := strings.Join(config.AllowedHeaders, ", ")
+	exposedHeaders := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			requested := r.Header.Get("Access-Control-Request-Method")
+			preflight := r.Method == http.MethodOptions && origin != "" && requested != ""
+			if !preflight {
+				if origin != "" && config.allowsOrigin(origin) {
+					config.writeOrigin(w, origin)
+					if exposedHeaders != "" {
+						w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			methods := routeMethods(routes, r, requested)
+			switch {
+			case len(methods) == 0:
+				NotFound(w, r)
+				return
+			case !config.allowsOrigin(origin):
+				WriteError(w, r, http.StatusForbidden, CodeOriginNotAllowed, "Origin "+origin+" is not allowed")
+				return
+			}
+			w.Header().Set("Allow", strings.Join(append(methods, http.MethodOptions), ", "))
+			if !contains(methods, requested) {
+				WriteError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, requested+" is not allowed on "+r.URL.Path)
+				return
+			}
+
+			config.writeOrigin(w, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if allowedHeaders != "" {
+				w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			}
+			if config.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allowsOrigin reports whether origin matches one of the allowed origins
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		scheme, domain, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+		prefix, suffix := scheme+"://", "."+domain
+		if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+		subdomain := origin[len(prefix) : len(origin)-len(suffix)]
+		if strings.Trim(subdomain, "abcdefghijklmnopqrstuvwxyz0123456789-.") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOrigin allows origin to read the response. The origin is echoed rather
+// than "*" unless any origin is allowed without credentials.
+func (c CORSConfig) writeOrigin(w http.ResponseWriter, origin string) {
+	if !c.AllowCredentials && contains(c.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// routeMethods returns the sorted methods of the routes matching the path of
+// r. Routes without a method matcher accept requested. Without routes,
+// requested is always allowed.
+func routeMethods(routes *mux.Router, r *http.Request, requested string) []string {
+	if routes == nil {
+		return []string{requested}
+	}
+	var methods []string
+	routes.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		candidates, err := route.GetMethods()
+		if err != nil {
+			candidates = []string{requested}
+		}
+		for _, method := range candidates {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			var match mux.RouteMatch
+			if !contains(methods, method) && route.Match(probe, &match) {
+				methods = append(methods, method)
+			}
+		}
+		return nil
+	})
+	sort.Strings(methods)
+	return methods
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
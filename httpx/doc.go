@@ -0,0 +1,9 @@
+// Package httpx holds the HTTP plumbing shared by the services: problem
+// details, request IDs, CORS, idempotency keys, health probes and the router
+// and middleware chain every service starts from. It also reports the same
+// error codes on failed gRPC calls.
+//
+// It is its own module so that both services build against one copy. The
+// services point at this directory with a replace directive, and releases
+// are tagged httpx/vX.Y.Z.
+package httpx
@@ -0,0 +1,114 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode is a stable, machine-readable identifier of an error. Clients
+// should match on codes rather than on the human-readable detail, which may
+// change. The gRPC APIs report the same codes in ResponseMetadata.
+type ErrorCode string
+
+// Error codes shared by all services. Each is documented in ErrorDocsURL.
+const (
+	CodeInvalidRequest       ErrorCode = "invalid_request"
+	CodeValidationFailed     ErrorCode = "validation_failed"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeMethodNotAllowed     ErrorCode = "method_not_allowed"
+	CodeOriginNotAllowed     ErrorCode = "origin_not_allowed"
+	CodeConflict             ErrorCode = "conflict"
+	CodeInvalidTransition    ErrorCode = "invalid_transition"
+	CodeIdempotencyKeyReused ErrorCode = "idempotency_key_reused"
+	CodeRequestInProgress    ErrorCode = "request_in_progress"
+	CodeForbidden            ErrorCode = "forbidden"
+	CodePaymentDeclined      ErrorCode = "payment_declined"
+	CodeUpstreamUnavailable  ErrorCode = "upstream_unavailable"
+	CodeUpstreamFailed       ErrorCode = "upstream_failed"
+	CodeInternal             ErrorCode = "internal"
+)
+
+// ProblemContentType is the media type of error responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// ErrorDocsURL documents every error code under an anchor named after it
+const ErrorDocsURL = "https://github.com/dev-shiki/devops-portfolio-platform/blob/main/docs/errors.md"
+
+// FieldError describes why one request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object. Type links to the
+// documentation of Code; Extensions holds additional members specific to
+// one kind of error.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      ErrorCode    `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblem describes an error answering r
+func NewProblem(r *http.Request, status int, code ErrorCode, detail string, fields ...FieldError) *Problem {
+	return &Problem{
+		Type:      ErrorDocsURL + "#" + string(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: RequestID(r),
+		Errors:    fields,
+	}
+}
+
+// MarshalJSON adds the extension members to the standard ones
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	body, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return body, err
+	}
+	members := make(map[string]interface{}, len(p.Extensions))
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	var standard map[string]interface{}
+	if err := json.Unmarshal(body, &standard); err != nil {
+		return nil, err
+	}
+	for name, value := range standard {
+		members[name] = value
+	}
+	return json.Marshal(members)
+}
+
+// WriteProblem writes p as the response
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// WriteError answers r with a problem of status and code
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code ErrorCode, detail string, fields ...FieldError) {
+	WriteProblem(w, NewProblem(r, status, code, detail, fields...))
+}
+
+// NotFound answers requests matching no route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, r, http.StatusNotFound, CodeNotFound, "No route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed answers requests whose route does not accept their method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
\ No newline at end of file
@@ -0,0 +1,87 @@
+package httpx
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+// GRPCRequestIDKey is the gRPC metadata key carrying request IDs, the
+// counterpart of RequestIDHeader
+const GRPCRequestIDKey = "x-request-id"
+
+// GRPCRequestID returns the request ID the caller sent in the metadata of
+// ctx, or a new one under the same rules as RequestIDMiddleware
+func GRPCRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(GRPCRequestIDKey); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= maxRequestIDLength {
+			return ids[0]
+		}
+	}
+	return NewRequestID()
+}
+
+// grpcErrorCodes maps gRPC status codes onto the error codes of the REST API
+var grpcErrorCodes = map[codes.Code]ErrorCode{
+	codes.InvalidArgument:    CodeValidationFailed,
+	codes.NotFound:           CodeNotFound,
+	codes.AlreadyExists:      CodeConflict,
+	codes.FailedPrecondition: CodeInvalidTransition,
+	codes.PermissionDenied:   CodeForbidden,
+	codes.Unavailable:        CodeUpstreamUnavailable,
+	codes.Unimplemented:      CodeMethodNotAllowed,
+}
+
+// GRPCErrorCode returns the error code of the REST API matching c
+func GRPCErrorCode(c codes.Code) ErrorCode {
+	if code, ok := grpcErrorCodes[c]; ok {
+		return code
+	}
+	return CodeInternal
+}
+
+// GRPCErrorDetails builds the message attached to the status of a failed
+// call, such as a service's ResponseMetadata, from its error code and fields.
+// start is when the call began.
+type GRPCErrorDetails func(ctx context.Context, code ErrorCode, fields []FieldError, start time.Time) protoiface.MessageV1
+
+// GRPCErrors reports error codes on failed gRPC calls the same way the REST
+// API does, in the details built by Details
+type GRPCErrors struct {
+	Details GRPCErrorDetails
+}
+
+// Error creates a status error whose details carry code and fields, for
+// errors the status code alone does not describe
+func (e GRPCErrors) Error(ctx context.Context, c codes.Code, code ErrorCode, msg string, fields ...FieldError) error {
+	return e.withDetails(ctx, status.New(c, msg), code, fields, time.Now())
+}
+
+// UnaryInterceptor attaches details with the error code mapped from the
+// status code to every failed call whose status has no details yet
+func (e GRPCErrors) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	st := status.Convert(err)
+	if len(st.Details()) > 0 {
+		return resp, err
+	}
+	return resp, e.withDetails(ctx, st, GRPCErrorCode(st.Code()), nil, start)
+}
+
+// withDetails returns st as an error with the code and fields attached
+func (e GRPCErrors) withDetails(ctx context.Context, st *status.Status, code ErrorCode, fields []FieldError, start time.Time) error {
+	detailed, err := st.WithDetails(e.Details(ctx, code, fields, start))
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
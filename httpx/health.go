@@ -0,0 +1,320 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// CheckKind says what a failing check means for the service
+type CheckKind int
+
+const (
+	// Liveness checks fail when the process is stuck and must be restarted.
+	// They fail readiness too.
+	Liveness CheckKind = iota
+	// Readiness checks fail when the service cannot serve requests for now
+	Readiness
+	// Optional checks are reported with readiness but never fail it
+	Optional
+)
+
+func (k CheckKind) String() string {
+	switch k {
+	case Liveness:
+		return "liveness"
+	case Readiness:
+		return "readiness"
+	}
+	return "optional"
+}
+
+// Health statuses of a report and of each check
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Checker reports the health of one component; a nil error means healthy
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckLock fails with the error of ctx when l cannot be taken before ctx is
+// done, e.g. because a holder of the lock is stuck. A probe that gives up
+// still takes and releases l once it is free.
+func CheckLock(ctx context.Context, l sync.Locker) error {
+	locked := make(chan struct{})
+	go func() {
+		l.Lock()
+		l.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Defaults of HealthConfig
+const (
+	DefaultHealthTimeout  = 2 * time.Second
+	DefaultHealthCacheTTL = 5 * time.Second
+)
+
+// HealthConfig bounds how long each check may run and how long its result is
+// reused. A zero CacheTTL runs the checks on every request.
+type HealthConfig struct {
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+// HealthConfigFromEnv reads HEALTH_CHECK_TIMEOUT and HEALTH_CACHE_TTL as
+// durations such as "500ms"
+func HealthConfigFromEnv() (HealthConfig, error) {
+	config := HealthConfig{Timeout: DefaultHealthTimeout, CacheTTL: DefaultHealthCacheTTL}
+	for name, value := range map[string]*time.Duration{
+		"HEALTH_CHECK_TIMEOUT": &config.Timeout,
+		"HEALTH_CACHE_TTL":     &config.CacheTTL,
+	} {
+		setting := os.Getenv(name)
+		if setting == "" {
+			continue
+		}
+		d, err := time.ParseDuration(setting)
+		if err != nil || d < 0 {
+			return config, fmt.Errorf("%s: invalid duration %q", name, setting)
+		}
+		*value = d
+	}
+	if config.Timeout == 0 {
+		return config, errors.New("HEALTH_CHECK_TIMEOUT must be positive")
+	}
+	return config, nil
+}
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	CheckedAt  string `json:"checked_at"`
+}
+
+// HealthReport is the outcome of the checks of a probe. Status is unhealthy
+// when a liveness or readiness check failed and degraded when only optional
+// checks did.
+type HealthReport struct {
+	Status  string        `json:"status"`
+	Service string        `json:"service"`
+	Checks  []CheckResult `json:"checks"`
+}
+
+// Details lists the service and the outcome of each check as flat strings,
+// the form the gRPC health checks report them in
+func (r HealthReport) Details() map[string]string {
+	details := map[string]string{"service": r.Service}
+	for _, check := range r.Checks {
+		details[check.Name] = check.Status
+		if check.Error != "" {
+			details[check.Name] += ": " + check.Error
+		}
+	}
+	return details
+}
+
+// Health runs the checks registered by the components of a service and
+// serves them as liveness and readiness probes. Checks run concurrently,
+// each under the configured timeout, and their results are cached so that
+// frequent probes do not load the dependencies.
+type Health struct {
+	service string
+	config  HealthConfig
+	now     func() time.Time
+
+	mutex  sync.RWMutex
+	checks []*healthCheck
+}
+
+// healthCheck is a registered checker and its cached result
+type healthCheck struct {
+	name    string
+	kind    CheckKind
+	checker Checker
+
+	mutex   sync.Mutex
+	result  CheckResult
+	expires time.Time
+}
+
+// NewHealth creates a registry without checks for service. A zero Timeout
+// uses DefaultHealthTimeout.
+func NewHealth(service string, config HealthConfig) *Health {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultHealthTimeout
+	}
+	return &Health{service: service, config: config, now: time.Now}
+}
+
+// Register adds a check named name. Checks are reported in registration
+// order.
+func (h *Health) Register(name string, kind CheckKind, checker Checker) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checks = append(h.checks, &healthCheck{name: name, kind: kind, checker: checker})
+}
+
+// Liveness runs the liveness checks
+func (h *Health) Liveness(ctx context.Context) HealthReport {
+	return h.report(ctx, func(kind CheckKind) bool { return kind == Liveness })
+}
+
+// Readiness runs every check
+func (h *Health) Readiness(ctx context.Context) HealthReport {
+	return h.report(ctx, func(CheckKind) bool { return true })
+}
+
+// LivenessHandler serves Liveness, answering 503 when it is unhealthy
+func (h *Health) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, h.Liveness(r.Context()))
+}
+
+// ReadinessHandler serves Readiness, answering 503 when it is unhealthy
+func (h *Health) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, h.Readiness(r.Context()))
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == StatusUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// report runs the selected checks concurrently
+func (h *Health) report(ctx context.Context, selected func(CheckKind) bool) HealthReport {
+	h.mutex.RLock()
+	var checks []*healthCheck
+	for _, check := range h.checks {
+		if selected(check.kind) {
+			checks = append(checks, check)
+		}
+	}
+	h.mutex.RUnlock()
+
+	report := HealthReport{Status: StatusHealthy, Service: h.service, Checks: make([]CheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *healthCheck) {
+			defer wg.Done()
+			report.Checks[i] = check.run(ctx, h)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, result := range report.Checks {
+		switch {
+		case result.Status == StatusHealthy:
+		case checks[i].kind == Optional:
+			if report.Status == StatusHealthy {
+				report.Status = StatusDegraded
+			}
+		default:
+			report.Status = StatusUnhealthy
+		}
+	}
+	return report
+}
+
+// run returns the cached result of the check, or runs it. Concurrent probes
+// wait for a single run. The check outlives a cancelled ctx so that a probe
+// hanging up does not cache a failure.
+func (c *healthCheck) run(ctx context.Context, h *Health) CheckResult {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if h.now().Before(c.expires) {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.config.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.checker.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", h.config.Timeout)
+	}
+
+	checkedAt := h.now()
+	c.result = CheckResult{
+		Name:       c.name,
+		Kind:       c.kind.String(),
+		Status:     StatusHealthy,
+		DurationMS: time.Since(start).Milliseconds(),
+		CheckedAt:  checkedAt.UTC().Format(time.RFC3339),
+	}
+	if err != nil {
+		c.result.Status = StatusUnhealthy
+		c.result.Error = err.Error()
+	}
+	c.expires = checkedAt.Add(h.config.CacheTTL)
+	return c.result
+}
+
+// Worker runs a background goroutine and, as a Checker, fails once the
+// goroutine has returned. The zero value is ready to use.
+type Worker struct {
+	mutex   sync.Mutex
+	stopped bool
+	err     error
+}
+
+// Go runs run in a new goroutine
+func (w *Worker) Go(run func() error) {
+	go func() {
+		err := run()
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		w.stopped, w.err = true, err
+	}()
+}
+
+// Check fails once the goroutine has returned
+func (w *Worker) Check(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	switch {
+	case w.stopped && w.err != nil:
+		return fmt.Errorf("stopped: %w", w.err)
+	case w.stopped:
+		return errors.New("stopped")
+	}
+	return nil
+}
\ No newline at end of file
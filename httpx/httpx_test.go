@@ -0,0 +1,662 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestWriteError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/things", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	WriteError(rr, req, http.StatusBadRequest, CodeValidationFailed, "error msg", FieldError{Field: "name", Message: "is required"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ProblemContentType, ct)
+	}
+	var resp Problem
+	json.NewDecoder(rr.Body).Decode(&resp)
+	want := Problem{
+		Type:      ErrorDocsURL + "#validation_failed",
+		Title:     "Bad Request",
+		Status:    http.StatusBadRequest,
+		Detail:    "error msg",
+		Instance:  "/things",
+		Code:      CodeValidationFailed,
+		RequestID: "req-1",
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0] != (FieldError{Field: "name", Message: "is required"}) {
+		t.Errorf("Expected one field error, got %+v", resp.Errors)
+	}
+	resp.Errors = nil
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("Expected %+v, got %+v", want, resp)
+	}
+}
+
+func TestProblemExtensions(t *testing.T) {
+	problem := NewProblem(httptest.NewRequest("POST", "/users", nil), http.StatusConflict, CodeConflict, "taken")
+	problem.Extensions = map[string]interface{}{"existing_user_id": 2, "code": "overridden"}
+	body, err := json.Marshal(problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp map[string]interface{}
+	json.Unmarshal(body, &resp)
+	if resp["existing_user_id"] != float64(2) || resp["code"] != "conflict" || resp["status"] != float64(http.StatusConflict) {
+		t.Errorf("Expected the extensions next to the standard members, got %s", body)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r)
+	}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "client-id")
+	handler.ServeHTTP(rr, req)
+	if seen != "client-id" || rr.Header().Get(RequestIDHeader) != "client-id" {
+		t.Errorf("Expected the client's request ID, got %q and %q", seen, rr.Header().Get(RequestIDHeader))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if len(seen) != 32 || rr.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("Expected a generated request ID, got %q and %q", seen, rr.Header().Get(RequestIDHeader))
+	}
+}
+
+func serveHealth(t *testing.T, handler http.HandlerFunc) (int, HealthReport) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/readyz", nil))
+	var report HealthReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return rr.Code, report
+}
+
+func TestHealthHandler(t *testing.T) {
+	h := NewHealth("order-service", HealthConfig{})
+	var storeErr, userErr error
+	h.Register("worker", Liveness, CheckerFunc(func(ctx context.Context) error { return nil }))
+	h.Register("store", Readiness, CheckerFunc(func(ctx context.Context) error { return storeErr }))
+	h.Register("users", Optional, CheckerFunc(func(ctx context.Context) error { return userErr }))
+
+	code, report := serveHealth(t, h.ReadinessHandler)
+	if code != http.StatusOK || report.Status != StatusHealthy || report.Service != "order-service" {
+		t.Errorf("Expected a healthy report, got %d %+v", code, report)
+	}
+	if len(report.Checks) != 3 || report.Checks[1].Name != "store" || report.Checks[1].Kind != "readiness" {
+		t.Errorf("Expected the checks in registration order, got %+v", report.Checks)
+	}
+
+	userErr = errors.New("connection refused")
+	if code, report := serveHealth(t, h.ReadinessHandler); code != http.StatusOK || report.Status != StatusDegraded {
+		t.Errorf("Expected a failing optional check to degrade readiness, got %d %+v", code, report)
+	}
+	storeErr = errors.New("disk full")
+	code, report = serveHealth(t, h.ReadinessHandler)
+	if code != http.StatusServiceUnavailable || report.Status != StatusUnhealthy {
+		t.Errorf("Expected a failing readiness check to fail readiness, got %d %+v", code, report)
+	}
+	if report.Checks[1].Status != StatusUnhealthy || report.Checks[1].Error != "disk full" {
+		t.Errorf("Expected the store error, got %+v", report.Checks[1])
+	}
+	code, report = serveHealth(t, h.LivenessHandler)
+	if code != http.StatusOK || len(report.Checks) != 1 || report.Checks[0].Name != "worker" {
+		t.Errorf("Expected liveness to run only the liveness checks, got %d %+v", code, report)
+	}
+}
+
+func TestHealthTimeoutAndCache(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHealth("order-service", HealthConfig{Timeout: 20 * time.Millisecond, CacheTTL: time.Minute})
+	h.now = func() time.Time { return now }
+	var mutex sync.Mutex
+	calls := 0
+	h.Register("slow", Readiness, CheckerFunc(func(ctx context.Context) error {
+		mutex.Lock()
+		calls++
+		mutex.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	report := h.Readiness(context.Background())
+	if report.Status != StatusUnhealthy || !strings.Contains(report.Checks[0].Error, "timed out") {
+		t.Errorf("Expected the slow check to time out, got %+v", report)
+	}
+	h.Readiness(context.Background())
+	now = now.Add(2 * time.Minute)
+	h.Readiness(context.Background())
+	mutex.Lock()
+	defer mutex.Unlock()
+	if calls != 2 {
+		t.Errorf("Expected the result to be cached for a minute, got %d runs", calls)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	var worker Worker
+	stop := make(chan error)
+	worker.Go(func() error { return <-stop })
+	if err := worker.Check(context.Background()); err != nil {
+		t.Errorf("Expected a running worker to be healthy, got %v", err)
+	}
+	stop <- errors.New("listener closed")
+	for i := 0; worker.Check(context.Background()) == nil && i < 100; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if err := worker.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "listener closed") {
+		t.Errorf("Expected a stopped worker to fail, got %v", err)
+	}
+}
+
+func TestCheckLock(t *testing.T) {
+	var mutex sync.RWMutex
+	if err := CheckLock(context.Background(), mutex.RLocker()); err != nil {
+		t.Errorf("Expected a free lock to be taken, got %v", err)
+	}
+
+	mutex.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := CheckLock(ctx, mutex.RLocker())
+	mutex.Unlock()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a held lock to time out, got %v", err)
+	}
+}
+
+func TestHealthReportDetails(t *testing.T) {
+	report := HealthReport{Status: StatusDegraded, Service: "order-service", Checks: []CheckResult{
+		{Name: "store", Status: StatusHealthy},
+		{Name: "user-service", Status: StatusUnhealthy, Error: "connection refused"},
+	}}
+	want := map[string]string{"service": "order-service", "store": "healthy", "user-service": "unhealthy: connection refused"}
+	if got := report.Details(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected details %v, got %v", want, got)
+	}
+}
+
+func TestHealthConfigFromEnv(t *testing.T) {
+	config, err := HealthConfigFromEnv()
+	if err != nil || config.Timeout != DefaultHealthTimeout || config.CacheTTL != DefaultHealthCacheTTL {
+		t.Errorf("Expected the defaults, got %+v, %v", config, err)
+	}
+	t.Setenv("HEALTH_CHECK_TIMEOUT", "500ms")
+	t.Setenv("HEALTH_CACHE_TTL", "0s")
+	config, err = HealthConfigFromEnv()
+	if err != nil || config.Timeout != 500*time.Millisecond || config.CacheTTL != 0 {
+		t.Errorf("Expected the settings from the environment, got %+v, %v", config, err)
+	}
+	for _, value := range []string{"soon", "-1s", "0s"} {
+		t.Setenv("HEALTH_CHECK_TIMEOUT", value)
+		if _, err := HealthConfigFromEnv(); err == nil {
+			t.Errorf("Expected HEALTH_CHECK_TIMEOUT %s to be rejected", value)
+		}
+	}
+}
+
+func TestAuthorHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	AuthorHandler(rr, httptest.NewRequest("GET", "/author", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.Contains(ct, "application/json") {
+		t.Errorf("Expected Content-Type application/json, got %s", ct)
+	}
+	var resp map[string]string
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["author"] == "" || resp["github"] == "" {
+		t.Errorf("Unexpected body: %v", resp)
+	}
+}
+
+func newCORSHandler(config CORSConfig) http.Handler {
+	r := mux.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	r.HandleFunc("/items", ok).Methods("GET", "POST")
+	r.HandleFunc("/items/{id:[0-9]+}", ok).Methods("GET")
+	r.HandleFunc("/items/{id:[0-9]+}", ok).Methods("DELETE")
+	return CORSMiddleware(config, r)(r)
+}
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	handler := newCORSHandler(CORSConfig{
+		AllowedOrigins:   []string{"https://shop.example.com", "https://*.example.org"},
+		AllowedHeaders:   []string{"Content-Type", "Idempotency-Key"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	preflight := func(origin, path, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := preflight("https://shop.example.com", "/items/7", "DELETE")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rr.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://shop.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "DELETE, GET",
+		"Access-Control-Allow-Headers":     "Content-Type, Idempotency-Key",
+		"Access-Control-Max-Age":           "600",
+	}
+	for name, value := range want {
+		if got := rr.Header().Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+	if vary := rr.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Origin" {
+		t.Errorf("Expected Vary: Origin, got %v", vary)
+	}
+
+	tests := []struct {
+		origin string
+		path   string
+		method string
+		status int
+	}{
+		{"https://api.example.org", "/items", "POST", http.StatusNoContent},
+		{"https://a.b.example.org", "/items", "GET", http.StatusNoContent},
+		{"https://example.org", "/items", "GET", http.StatusForbidden},
+		{"https://evil.com", "/items", "GET", http.StatusForbidden},
+		{"http://shop.example.com", "/items", "GET", http.StatusForbidden},
+		{"https://shop.example.com", "/items", "PUT", http.StatusMethodNotAllowed},
+		{"https://shop.example.com", "/missing", "GET", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rr := preflight(tt.origin, tt.path, tt.method); rr.Code != tt.status {
+			t.Errorf("Preflight %s %s from %s: expected %d, got %d", tt.method, tt.path, tt.origin, tt.status, rr.Code)
+		}
+	}
+	if rr := preflight("https://shop.example.com", "/items", "PUT"); rr.Header().Get("Allow") != "GET, POST, OPTIONS" {
+		t.Errorf("Expected the route's methods in Allow, got %q", rr.Header().Get("Allow"))
+	}
+}
+
+func TestCORSMiddleware_SimpleRequest(t *testing.T) {
+	handler := newCORSHandler(CORSConfig{
+		AllowedOrigins: []string{"*"},
+		ExposedHeaders: []string{"X-Total-Count"},
+	})
+
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set("Origin", "https://anywhere.test")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Errorf("Expected the request to reach the handler, got %d", rr.Code)
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "*" || rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("Expected any origin without credentials, got %v", rr.Header())
+	}
+	if rr.Header().Get("Access-Control-Expose-Headers") != "X-Total-Count" {
+		t.Errorf("Expected exposed headers, got %q", rr.Header().Get("Access-Control-Expose-Headers"))
+	}
+
+	handler = newCORSHandler(CORSConfig{AllowedOrigins: []string{"https://shop.example.com"}})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for a disallowed origin, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestCORSConfigFromEnv(t *testing.T) {
+	defaults := CORSConfig{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"Content-Type"}}
+
+	config, err := CORSConfigFromEnv(defaults)
+	if err != nil || len(config.AllowedOrigins) != 1 || config.AllowedHeaders[0] != "Content-Type" {
+		t.Errorf("Expected the defaults, got %+v, %v", config, err)
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://shop.example.com, https://*.example.org")
+	t.Setenv("CORS_EXPOSED_HEADERS", "X-Total-Count,X-Next-Page-Token")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("CORS_MAX_AGE", "1h")
+	config, err = CORSConfigFromEnv(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.AllowedOrigins) != 2 || config.AllowedOrigins[1] != "https://*.example.org" {
+		t.Errorf("Expected two origins, got %q", config.AllowedOrigins)
+	}
+	if len(config.ExposedHeaders) != 2 || !config.AllowCredentials || config.MaxAge != time.Hour {
+		t.Errorf("Expected the settings from the environment, got %+v", config)
+	}
+
+	for name, value := range map[string]string{
+		"CORS_ALLOWED_ORIGINS":   "*",
+		"CORS_ALLOW_CREDENTIALS": "sometimes",
+		"CORS_MAX_AGE":           "-1m",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := CORSConfigFromEnv(defaults); err == nil {
+				t.Errorf("Expected %s=%s to be rejected", name, value)
+			}
+		})
+	}
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+	for _, origin := range []string{"shop.example.com", "https://*", "https://shop.*.com", "https://shop.example.com/path"} {
+		t.Setenv("CORS_ALLOWED_ORIGINS", origin)
+		if _, err := CORSConfigFromEnv(defaults); err == nil {
+			t.Errorf("Expected origin %q to be rejected", origin)
+		}
+	}
+}
+
+// countingHandler creates a resource on every call and answers 201 with its
+// number, or status when it is set. With release set, it signals started and
+// waits for release to be closed.
+type countingHandler struct {
+	mutex   sync.Mutex
+	calls   int
+	status  int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.release != nil {
+		h.started <- struct{}{}
+		<-h.release
+	}
+	io.ReadAll(r.Body)
+	h.mutex.Lock()
+	h.calls++
+	calls := h.calls
+	h.mutex.Unlock()
+	if h.status != 0 {
+		WriteError(w, r, h.status, CodeInternal, "failed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"id": calls})
+}
+
+func serveIdempotent(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/things", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+		req.Header.Set("Authorization", "Bearer test")
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// grpcTestErrors reports the error code and fields of failed calls in a
+// StringValue
+var grpcTestErrors = GRPCErrors{Details: func(ctx context.Context, code ErrorCode, fields []FieldError, start time.Time) protoiface.MessageV1 {
+	detail := string(code)
+	for _, field := range fields {
+		detail += " " + field.Field
+	}
+	return wrapperspb.String(detail)
+}}
+
+// grpcErrorDetail returns the detail grpcTestErrors attached to err
+func grpcErrorDetail(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if value, ok := detail.(*wrapperspb.StringValue); ok {
+			return value.Value
+		}
+	}
+	return ""
+}
+
+func TestGRPCRequestID(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(GRPCRequestIDKey, "req-1"))
+	if id := GRPCRequestID(ctx); id != "req-1" {
+		t.Errorf("Expected the caller's request ID, got %q", id)
+	}
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(GRPCRequestIDKey, strings.Repeat("x", maxRequestIDLength+1)))
+	if id := GRPCRequestID(ctx); len(id) != len(NewRequestID()) {
+		t.Errorf("Expected an overlong ID to be replaced like over HTTP, got %q", id)
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	err := grpcTestErrors.Error(context.Background(), codes.InvalidArgument, CodeValidationFailed, "bad", FieldError{Field: "name"})
+	if status.Code(err) != codes.InvalidArgument || grpcErrorDetail(err) != "validation_failed name" {
+		t.Errorf("Expected the code and fields in the details, got %v", err)
+	}
+
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{status.Error(codes.NotFound, "missing"), "not_found"},
+		{status.Error(codes.DataLoss, "lost"), "internal"},
+		{err, "validation_failed name"},
+	} {
+		_, got := grpcTestErrors.UnaryInterceptor(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, tt.err
+		})
+		if grpcErrorDetail(got) != tt.want {
+			t.Errorf("%v: expected detail %q, got %q", tt.err, tt.want, grpcErrorDetail(got))
+		}
+	}
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	handler := &countingHandler{}
+	h := NewIdempotency(time.Hour).Middleware(handler)
+
+	first := serveIdempotent(h, "key-1", `{"name":"a"}`)
+	retry := serveIdempotent(h, "key-1", `{"name":"a"}`)
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the retry to replay %d %s, got %d %s", first.Code, first.Body, retry.Code, retry.Body)
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the stored headers on the replay, got %v", retry.Header())
+	}
+	if handler.calls != 1 {
+		t.Errorf("Expected one call to the handler, got %d", handler.calls)
+	}
+
+	if rr := serveIdempotent(h, "key-1", `{"name":"b"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a reused key with a different body, got %d", rr.Code)
+	}
+	serveIdempotent(h, "key-2", `{"name":"a"}`)
+	serveIdempotent(h, "", `{"name":"a"}`)
+	if handler.calls != 3 {
+		t.Errorf("Expected new and missing keys to reach the handler, got %d calls", handler.calls)
+	}
+	if rr := serveIdempotent(h, strings.Repeat("k", 256), `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an overlong key, got %d", rr.Code)
+	}
+	req := httptest.NewRequest("POST", "/things", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-3")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || handler.calls != 3 {
+		t.Errorf("Expected 400 for a key without an Authorization header, got %d", rr.Code)
+	}
+}
+
+func TestIdempotencyExpiresKeys(t *testing.T) {
+	handler := &countingHandler{}
+	cache := NewIdempotency(time.Minute)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	h := cache.Middleware(handler)
+
+	serveIdempotent(h, "key", `{}`)
+	now = now.Add(30 * time.Second)
+	serveIdempotent(h, "key", `{}`)
+	if handler.calls != 1 {
+		t.Fatalf("Expected the key to be live within the TTL, got %d calls", handler.calls)
+	}
+	now = now.Add(time.Minute)
+	if rr := serveIdempotent(h, "key", `{"other":true}`); rr.Code != http.StatusCreated || handler.calls != 2 {
+		t.Errorf("Expected an expired key to be reusable, got %d after %d calls", rr.Code, handler.calls)
+	}
+	if len(cache.responses) != 1 {
+		t.Errorf("Expected expired responses to be swept, got %d", len(cache.responses))
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	handler := &countingHandler{status: http.StatusInternalServerError}
+	h := NewIdempotency(time.Hour).Middleware(handler)
+
+	serveIdempotent(h, "key", `{}`)
+	handler.status = 0
+	if rr := serveIdempotent(h, "key", `{}`); rr.Code != http.StatusCreated || handler.calls != 2 {
+		t.Errorf("Expected a retry after a server error to run again, got %d after %d calls", rr.Code, handler.calls)
+	}
+}
+
+func TestIdempotencyConcurrentRequests(t *testing.T) {
+	handler := &countingHandler{started: make(chan struct{}), release: make(chan struct{})}
+	h := NewIdempotency(time.Hour).Middleware(handler)
+
+	// The first request holds the key until released; every concurrent
+	// retry is turned away without reaching the handler
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serveIdempotent(h, "key", `{}`) }()
+	<-handler.started
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rr := serveIdempotent(h, "key", `{}`); rr.Code != http.StatusConflict {
+				t.Errorf("Expected 409 while the first request runs, got %d", rr.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	close(handler.release)
+
+	if first := <-done; first.Code != http.StatusCreated || handler.calls != 1 {
+		t.Errorf("Expected one created response, got %d after %d calls", first.Code, handler.calls)
+	}
+}
+
+func TestIdempotencyScopesKeysByCaller(t *testing.T) {
+	handler := &countingHandler{}
+	h := NewIdempotency(time.Hour).Middleware(handler)
+
+	for _, auth := range []string{"Bearer alice", "Bearer bob", "Bearer alice"} {
+		req := httptest.NewRequest("POST", "/things", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		req.Header.Set("Authorization", auth)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if handler.calls != 2 {
+		t.Errorf("Expected one call per caller, got %d", handler.calls)
+	}
+}
+
+func TestIdempotencyLimits(t *testing.T) {
+	handler := &countingHandler{}
+	cache := NewIdempotency(time.Hour)
+	cache.limit = 2
+	h := cache.Middleware(handler)
+
+	if rr := serveIdempotent(h, "big", strings.Repeat("x", maxIdempotentBodySize+1)); rr.Code != http.StatusRequestEntityTooLarge || handler.calls != 0 {
+		t.Errorf("Expected 413 for an oversized body, got %d after %d calls", rr.Code, handler.calls)
+	}
+
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		serveIdempotent(h, key, `{}`)
+	}
+	if len(cache.responses) != 2 {
+		t.Errorf("Expected the cache to hold 2 keys, got %d", len(cache.responses))
+	}
+	if serveIdempotent(h, "key-3", `{}`); handler.calls != 3 {
+		t.Errorf("Expected the newest key to be kept, got %d calls", handler.calls)
+	}
+}
+
+func TestIdempotencyReplayKeepsCORSHeaders(t *testing.T) {
+	handler := &countingHandler{}
+	idempotent := NewIdempotency(time.Hour).Middleware(handler)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+		idempotent.ServeHTTP(w, r)
+	})
+
+	for _, origin := range []string{"https://a.example.com", "https://b.example.com"} {
+		req := httptest.NewRequest("POST", "/things", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		req.Header.Set("Authorization", "Bearer test")
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("Expected the CORS headers of %s, got %s", origin, got)
+		}
+	}
+	if handler.calls != 1 {
+		t.Errorf("Expected the second request to be replayed, got %d calls", handler.calls)
+	}
+}
+
+func TestServerHandler(t *testing.T) {
+	r := NewRouter(NewHealth("order-service", HealthConfig{}))
+	r.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }).Methods("GET")
+	handler := Handler(r, CORSConfig{AllowedOrigins: []string{"https://shop.example.com"}})
+
+	for _, path := range []string{"/livez", "/readyz", "/health", "/author", "/items"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || rr.Header().Get(RequestIDHeader) == "" {
+			t.Errorf("GET %s: expected 200 with a request ID, got %d %v", path, rr.Code, rr.Header())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("DELETE", "/items", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("Expected a 405 problem, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/missing", nil))
+	if rr.Code != http.StatusNotFound || rr.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("Expected a 404 problem, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	req := httptest.NewRequest("OPTIONS", "/items", nil)
+	req.Header.Set("Origin", "https://shop.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://shop.example.com" {
+		t.Errorf("Expected the preflight to be allowed, got %d %v", rr.Code, rr.Header())
+	}
+}
\ No newline at end of file
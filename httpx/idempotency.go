@@ -0,0 +1,239 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader names the request header clients set to make a
+// request safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed from the cache
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodySize bounds the request bodies buffered for a key
+const maxIdempotentBodySize = 1 << 20
+
+// defaultIdempotencyLimit is the number of keys a cache holds at most
+const defaultIdempotencyLimit = 10000
+
+// idempotentResponse is a stored response, or a request still in flight
+// while done is false
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// Idempotency remembers the response to each request carrying an
+// Idempotency-Key for a TTL. A retry with the same key and body gets the
+// stored response unchanged; the same key with a different body gets 422,
+// and a retry while the first request is still running gets 409. Server
+// errors are not stored, so they can be retried. Keys are scoped to the
+// method, path and caller, and the cache holds a bounded number of them,
+// evicting the stored responses closest to expiry first.
+//
+// Callers are told apart by their Authorization header, so keyed requests
+// without one are rejected. Responses live in the memory of one process: a
+// retry that reaches another replica runs again.
+type Idempotency struct {
+	ttl   time.Duration
+	limit int
+	now   func() time.Time
+	mutex sync.Mutex
+
+	responses map[string]*idempotentResponse
+	nextSweep time.Time
+}
+
+// NewIdempotency creates an idempotency cache keeping responses for ttl
+func NewIdempotency(ttl time.Duration) *Idempotency {
+	return &Idempotency{ttl: ttl, limit: defaultIdempotencyLimit, now: time.Now, responses: make(map[string]*idempotentResponse)}
+}
+
+// Middleware applies the cache to requests served by next
+func (c *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			WriteError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Idempotency-Key is too long")
+			return
+		}
+		caller := idempotencyCaller(r)
+		if caller == "" {
+			WriteError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Idempotency-Key requires an Authorization header")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			WriteError(w, r, http.StatusRequestEntityTooLarge, CodeInvalidRequest, "Request body is too large")
+			return
+		}
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scoped := r.Method + " " + r.URL.Path + " " + caller + " " + key
+		fingerprint := sha256.Sum256(body)
+		stored, fresh, full := c.reserve(scoped, fingerprint)
+		switch {
+		case full:
+			WriteError(w, r, http.StatusServiceUnavailable, CodeRequestInProgress, "Too many requests with an Idempotency-Key are in progress")
+			return
+		case !fresh && stored.fingerprint != fingerprint:
+			WriteError(w, r, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was used with a different request body")
+			return
+		case !fresh && !stored.done:
+			WriteError(w, r, http.StatusConflict, CodeRequestInProgress, "A request with this Idempotency-Key is still in progress")
+			return
+		case !fresh:
+			for name, values := range stored.header {
+				// The replay keeps the request ID and CORS headers set for
+				// the request answering it
+				if !perRequestHeader(name) {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(stored.status)
+			w.Write(stored.body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// A panicking or failing handler releases the key for a retry
+			c.complete(scoped, recorder)
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// idempotencyCaller identifies who sent r by a digest of its credentials, or
+// returns "" when it has none. Remote addresses are not used, as every client
+// behind a proxy shares one.
+func idempotencyCaller(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(auth))
+	return string(sum[:])
+}
+
+// perRequestHeader reports whether the response header name depends on the
+// request being answered rather than the stored one
+func perRequestHeader(name string) bool {
+	return name == http.CanonicalHeaderKey(RequestIDHeader) || name == "Vary" ||
+		strings.HasPrefix(name, "Access-Control-")
+}
+
+// reserve returns the entry stored under key, or records a new in-flight
+// entry and reports it as fresh. It reports full when the cache is at its
+// limit and every entry is still in flight.
+func (c *Idempotency) reserve(key string, fingerprint [sha256.Size]byte) (stored idempotentResponse, fresh, full bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	if now.After(c.nextSweep) {
+		for k, stored := range c.responses {
+			if stored.done && now.After(stored.expires) {
+				delete(c.responses, k)
+			}
+		}
+		c.nextSweep = now.Add(c.ttl)
+	}
+
+	if stored, exists := c.responses[key]; exists && !(stored.done && now.After(stored.expires)) {
+		return *stored, false, false
+	}
+	if _, exists := c.responses[key]; !exists && len(c.responses) >= c.limit && !c.evictLocked() {
+		return idempotentResponse{}, false, true
+	}
+	c.responses[key] = &idempotentResponse{fingerprint: fingerprint}
+	return idempotentResponse{}, true, false
+}
+
+// evictLocked forgets the stored response that expires first, and reports
+// false when every entry is still in flight. The caller must hold c.mutex.
+func (c *Idempotency) evictLocked() bool {
+	var oldest string
+	var expires time.Time
+	for k, stored := range c.responses {
+		if stored.done && (oldest == "" || stored.expires.Before(expires)) {
+			oldest, expires = k, stored.expires
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	delete(c.responses, oldest)
+	return true
+}
+
+// complete stores the recorded response under key, or forgets key when the
+// response is a server error or was never written
+func (c *Idempotency) complete(key string, recorder *responseRecorder) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !recorder.wroteHeader || recorder.status >= http.StatusInternalServerError {
+		delete(c.responses, key)
+		return
+	}
+	stored := c.responses[key]
+	stored.done = true
+	stored.status = recorder.status
+	stored.header = recorder.header
+	stored.body = recorder.body.Bytes()
+	stored.expires = c.now().Add(c.ttl)
+}
+
+// responseRecorder copies a response while passing it through
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	r.header = r.ResponseWriter.Header().Clone()
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,34 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// NewRouter creates the router of a service. Requests matching no route are
+// answered with problem details, and the probes of health are served on
+// /livez and /readyz, with /health kept as an alias of /readyz.
+func NewRouter(health *Health) *mux.Router {
+	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(MethodNotAllowed)
+	r.HandleFunc("/livez", health.LivenessHandler).Methods("GET")
+	r.HandleFunc("/readyz", health.ReadinessHandler).Methods("GET")
+	r.HandleFunc("/health", health.ReadinessHandler).Methods("GET")
+	r.HandleFunc("/author", AuthorHandler).Methods("GET")
+	return r
+}
+
+// Handler wraps routes in the middleware every service runs: request IDs
+// first, then CORS. CORS wraps the router instead of being added with Use
+// because mux only runs middleware for matched routes and preflights match
+// none.
+func Handler(routes *mux.Router, cors CORSConfig) http.Handler {
+	return RequestIDMiddleware(CORSMiddleware(cors, routes)(routes))
+}
+
+// ListenAndServe serves routes on addr through Handler
+func ListenAndServe(addr string, routes *mux.Router, cors CORSConfig) error {
+	return http.ListenAndServe(addr, Handler(routes, cors))
+}
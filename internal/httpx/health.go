@@ -1,10 +0,0 @@
-package httpx
-
-import (
-	"net/http"
-)
-
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"ok"}`))
-} 
\ No newline at end of file
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/runtime/protoiface"
+	pb "order-service/proto"
+)
+
+// grpcErrors reports error codes on failed calls in a ResponseMetadata
+var grpcErrors = httpx.GRPCErrors{Details: errorDetails}
+
+// grpcError creates a status error whose details carry code and fields in a
+// ResponseMetadata, for errors the status code alone does not describe
+func grpcError(ctx context.Context, c codes.Code, code httpx.ErrorCode, msg string, fields ...httpx.FieldError) error {
+	return grpcErrors.Error(ctx, c, code, msg, fields...)
+}
+
+// errorDetails builds the ResponseMetadata attached to a failed call
+func errorDetails(ctx context.Context, code httpx.ErrorCode, fields []httpx.FieldError, start time.Time) protoiface.MessageV1 {
+	md := responseMetadata(ctx, start)
+	md.ErrorCode = string(code)
+	for _, field := range fields {
+		md.FieldErrors = append(md.FieldErrors, &pb.FieldError{Field: field.Field, Message: field.Message})
+	}
+	return md
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"order-service/internal/userclient"
+	pb "order-service/proto"
+)
+
+// errorMetadata returns the ResponseMetadata attached to a status error
+func errorMetadata(t *testing.T, err error) *pb.ResponseMetadata {
+	t.Helper()
+	for _, detail := range status.Convert(err).Details() {
+		if md, ok := detail.(*pb.ResponseMetadata); ok {
+			return md
+		}
+	}
+	t.Fatalf("Expected ResponseMetadata in the details of %v", err)
+	return nil
+}
+
+func TestGRPCErrorMetadata(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), httpx.GRPCRequestIDKey, "req-404")
+	_, err := client.GetOrder(ctx, &pb.GetOrderRequest{OrderId: 999})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound, got %v", err)
+	}
+	md := errorMetadata(t, err)
+	if md.ErrorCode != string(httpx.CodeNotFound) || md.RequestId != "req-404" {
+		t.Errorf("Expected not_found for request req-404, got %v", md)
+	}
+
+	_, err = client.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{OrderId: 1, NewStatus: pb.OrderStatus_ORDER_STATUS_DELIVERED})
+	if md := errorMetadata(t, err); md.ErrorCode != string(httpx.CodeInvalidTransition) {
+		t.Errorf("Expected invalid_transition, got %v", md)
+	}
+}
+
+func TestGRPCErrorMetadata_FieldErrors(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: userclient.ErrNotFound}
+	client := newTestGRPCClient(t, store)
+
+	_, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId:      42,
+		ProductName: "Keyboard",
+		Quantity:    1,
+		Price:       45.5,
+	})
+	md := errorMetadata(t, err)
+	if md.ErrorCode != string(httpx.CodeValidationFailed) {
+		t.Errorf("Expected validation_failed, got %s", md.ErrorCode)
+	}
+	if len(md.FieldErrors) != 1 || md.FieldErrors[0].Field != "user_id" {
+		t.Errorf("Expected a user_id field error, got %v", md.FieldErrors)
+	}
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"order-service/internal/payment"
+	pb "order-service/proto"
+)
+
+// serviceVersion is reported in the metadata of every gRPC response
+const serviceVersion = "1.0.0"
+
+// actorMetadataKey is the incoming gRPC metadata key naming the caller
+// responsible for a change
+const actorMetadataKey = "x-actor"
+
+// orderStatusToProto maps the REST order statuses onto the proto enum
+var orderStatusToProto = map[string]pb.OrderStatus{
+	StatusPending:    pb.OrderStatus_ORDER_STATUS_PENDING,
+	StatusConfirmed:  pb.OrderStatus_ORDER_STATUS_CONFIRMED,
+	StatusProcessing: pb.OrderStatus_ORDER_STATUS_PROCESSING,
+	StatusShipped:    pb.OrderStatus_ORDER_STATUS_SHIPPED,
+	StatusDelivered:  pb.OrderStatus_ORDER_STATUS_DELIVERED,
+	StatusCancelled:  pb.OrderStatus_ORDER_STATUS_CANCELLED,
+}
+
+// orderStatusFromProto maps the proto enum onto the REST order statuses
+var orderStatusFromProto = map[pb.OrderStatus]string{
+	pb.OrderStatus_ORDER_STATUS_PENDING:    StatusPending,
+	pb.OrderStatus_ORDER_STATUS_CONFIRMED:  StatusConfirmed,
+	pb.OrderStatus_ORDER_STATUS_PROCESSING: StatusProcessing,
+	pb.OrderStatus_ORDER_STATUS_SHIPPED:    StatusShipped,
+	pb.OrderStatus_ORDER_STATUS_DELIVERED:  StatusDelivered,
+	pb.OrderStatus_ORDER_STATUS_CANCELLED:  StatusCancelled,
+}
+
+// paymentStatusToProto maps the REST payment statuses onto the proto enum
+var paymentStatusToProto = map[string]pb.PaymentStatus{
+	PaymentStatusPending:    pb.PaymentStatus_PAYMENT_STATUS_PENDING,
+	PaymentStatusProcessing: pb.PaymentStatus_PAYMENT_STATUS_PROCESSING,
+	PaymentStatusCompleted:  pb.PaymentStatus_PAYMENT_STATUS_COMPLETED,
+	PaymentStatusFailed:     pb.PaymentStatus_PAYMENT_STATUS_FAILED,
+	PaymentStatusCancelled:  pb.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+	PaymentStatusRefunded:   pb.PaymentStatus_PAYMENT_STATUS_REFUNDED,
+}
+
+// paymentStatusFromProto maps the proto enum onto the REST payment statuses
+var paymentStatusFromProto = map[pb.PaymentStatus]string{
+	pb.PaymentStatus_PAYMENT_STATUS_PENDING:    PaymentStatusPending,
+	pb.PaymentStatus_PAYMENT_STATUS_PROCESSING: PaymentStatusProcessing,
+	pb.PaymentStatus_PAYMENT_STATUS_COMPLETED:  PaymentStatusCompleted,
+	pb.PaymentStatus_PAYMENT_STATUS_FAILED:     PaymentStatusFailed,
+	pb.PaymentStatus_PAYMENT_STATUS_CANCELLED:  PaymentStatusCancelled,
+	pb.PaymentStatus_PAYMENT_STATUS_REFUNDED:   PaymentStatusRefunded,
+}
+
+// paymentMethodToProto maps payment methods onto the proto enum
+var paymentMethodToProto = map[string]pb.PaymentMethod{
+	payment.MethodCreditCard:     pb.PaymentMethod_PAYMENT_METHOD_CREDIT_CARD,
+	payment.MethodDebitCard:      pb.PaymentMethod_PAYMENT_METHOD_DEBIT_CARD,
+	payment.MethodBankTransfer:   pb.PaymentMethod_PAYMENT_METHOD_BANK_TRANSFER,
+	payment.MethodDigitalWallet:  pb.PaymentMethod_PAYMENT_METHOD_DIGITAL_WALLET,
+	payment.MethodCryptocurrency: pb.PaymentMethod_PAYMENT_METHOD_CRYPTOCURRENCY,
+}
+
+// shippingMethodToProto maps shipping methods onto the proto enum
+var shippingMethodToProto = map[string]pb.ShippingMethod{
+	ShippingStandard:  pb.ShippingMethod_SHIPPING_METHOD_STANDARD,
+	ShippingExpress:   pb.ShippingMethod_SHIPPING_METHOD_EXPRESS,
+	ShippingOvernight: pb.ShippingMethod_SHIPPING_METHOD_OVERNIGHT,
+	ShippingPickup:    pb.ShippingMethod_SHIPPING_METHOD_PICKUP,
+}
+
+// shippingMethodFromProto maps the proto enum onto shipping methods; UNKNOWN
+// selects the default method
+var shippingMethodFromProto = map[pb.ShippingMethod]string{
+	pb.ShippingMethod_SHIPPING_METHOD_UNKNOWN:   "",
+	pb.ShippingMethod_SHIPPING_METHOD_STANDARD:  ShippingStandard,
+	pb.ShippingMethod_SHIPPING_METHOD_EXPRESS:   ShippingExpress,
+	pb.ShippingMethod_SHIPPING_METHOD_OVERNIGHT: ShippingOvernight,
+	pb.ShippingMethod_SHIPPING_METHOD_PICKUP:    ShippingPickup,
+}
+
+// orderGRPCServer implements pb.OrderServiceServer on top of OrderStore
+type orderGRPCServer struct {
+	pb.UnimplementedOrderServiceServer
+	store  *OrderStore
+	health *httpx.Health
+}
+
+// NewGRPCServer creates a gRPC server exposing the OrderService API
+func NewGRPCServer(store *OrderStore, health *httpx.Health) *grpc.Server {
+	srv := grpc.NewServer(grpc.UnaryInterceptor(grpcErrors.UnaryInterceptor))
+	pb.RegisterOrderServiceServer(srv, &orderGRPCServer{store: store, health: health})
+	return srv
+}
+
+// GetOrder retrieves an order by ID
+func (s *orderGRPCServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
+	start := time.Now()
+
+	order, exists := s.store.GetOrder(int(req.GetOrderId()))
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "order %d not found", req.GetOrderId())
+	}
+
+	return &pb.GetOrderResponse{
+		Order:    toProtoOrder(order),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// ListOrders returns the page of orders selected by the filter, sort and
+// paging fields, with the same semantics as GET /orders
+func (s *orderGRPCServer) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	start := time.Now()
+
+	query, err := orderQueryFromProto(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	page, err := s.store.ListOrders(query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &pb.ListOrdersResponse{
+		Orders:        toProtoOrders(page.Orders),
+		NextPageToken: page.NextPageToken,
+		TotalCount:    int32(page.TotalCount),
+		Metadata:      responseMetadata(ctx, start),
+	}, nil
+}
+
+// orderQueryFromProto maps a ListOrders request onto an OrderQuery
+func orderQueryFromProto(req *pb.ListOrdersRequest) (OrderQuery, error) {
+	filter := req.GetFilter()
+	query := OrderQuery{
+		PageSize:  int(req.GetPageSize()),
+		PageToken: req.GetPageToken(),
+		UserID:    int(filter.GetUserId()),
+		Currency:  filter.GetCurrency(),
+		SortBy:    req.GetSortBy(),
+	}
+	for _, amount := range []struct {
+		name  string
+		value float64
+		into  *int64
+	}{
+		{"min_amount", filter.GetMinAmount(), &query.MinAmount},
+		{"max_amount", filter.GetMaxAmount(), &query.MaxAmount},
+	} {
+		if amount.value == 0 {
+			continue
+		}
+		if query.Currency == "" {
+			return query, fmt.Errorf("%w: %s requires currency", ErrInvalidQuery, amount.name)
+		}
+		m, err := moneyFromFloat(amount.value, query.Currency)
+		if err != nil {
+			return query, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, amount.name, err)
+		}
+		*amount.into = m.Amount
+	}
+	if st := filter.GetStatus(); st != pb.OrderStatus_ORDER_STATUS_UNKNOWN {
+		var known bool
+		if query.Status, known = orderStatusFromProto[st]; !known {
+			return query, fmt.Errorf("%w: unsupported status filter %v", ErrInvalidQuery, st)
+		}
+	}
+	if st := filter.GetPaymentStatus(); st != pb.PaymentStatus_PAYMENT_STATUS_UNKNOWN {
+		var known bool
+		if query.PaymentStatus, known = paymentStatusFromProto[st]; !known {
+			return query, fmt.Errorf("%w: unsupported payment status filter %v", ErrInvalidQuery, st)
+		}
+	}
+	if filter.GetCreatedAfter() != nil {
+		query.CreatedAfter = filter.GetCreatedAfter().AsTime()
+	}
+	if filter.GetCreatedBefore() != nil {
+		query.CreatedBefore = filter.GetCreatedBefore().AsTime()
+	}
+	if req.GetSortOrder() == pb.SortOrder_SORT_ORDER_DESC {
+		query.SortOrder = SortDesc
+	}
+	return query, nil
+}
+
+// CreateOrder creates a new order
+func (s *orderGRPCServer) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*pb.CreateOrderResponse, error) {
+	start := time.Now()
+
+	if req.GetUserId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "all fields are required and must be valid")
+	}
+	items, err := fromProtoItems(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	_, total, err := priceItems(items)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	shipping, err := fromProtoShipping(req.GetShipping())
+	if err == nil && shipping != nil {
+		_, err = s.store.shippingRates.priceShipping(*shipping, total, totalUnits(items), time.Now())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	needsCheck, err := s.store.verifyOrderUser(ctx, int(req.GetUserId()))
+	if errors.Is(err, errUnknownUser) {
+		return nil, grpcError(ctx, codes.FailedPrecondition, httpx.CodeValidationFailed, fmt.Sprintf("user %d does not exist", req.GetUserId()),
+			httpx.FieldError{Field: "user_id", Message: "no such user"})
+	}
+	if errors.Is(err, errUserSuspended) {
+		return nil, status.Errorf(codes.PermissionDenied, "user %d is suspended", req.GetUserId())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	order, err := s.store.createOrder(int(req.GetUserId()), items, shipping, needsCheck)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "create order: %v", err)
+	}
+
+	return &pb.CreateOrderResponse{
+		Order:    toProtoOrder(order),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// UpdateOrderStatus changes the status of an existing order
+func (s *orderGRPCServer) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrderStatusRequest) (*pb.UpdateOrderStatusResponse, error) {
+	start := time.Now()
+
+	newStatus, ok := orderStatusFromProto[req.GetNewStatus()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid status %s", req.GetNewStatus())
+	}
+
+	var order *Order
+	var err error
+	if newStatus == StatusShipped && req.GetTrackingNumber() != "" {
+		order, err = s.store.ShipOrder(int(req.GetOrderId()), req.GetTrackingNumber(), grpcActor(ctx), req.GetReason())
+	} else {
+		order, err = s.store.TransitionOrder(int(req.GetOrderId()), newStatus, grpcActor(ctx), req.GetReason())
+	}
+	if err != nil {
+		return nil, storeError(err, req.GetOrderId())
+	}
+
+	return &pb.UpdateOrderStatusResponse{
+		Order:    toProtoOrder(order),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// CancelOrder moves an order into the cancelled status
+func (s *orderGRPCServer) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	start := time.Now()
+
+	reason := req.GetReasonCode()
+	if reason == "" {
+		reason = CancelReasonOther
+	}
+	order, err := s.store.CancelOrder(ctx, int(req.GetOrderId()), reason, req.GetReason(), grpcActor(ctx))
+	if err != nil {
+		return nil, storeError(err, req.GetOrderId())
+	}
+
+	return &pb.CancelOrderResponse{
+		Success:  true,
+		Order:    toProtoOrder(order),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// GetOrdersByUser returns the orders of a user, optionally filtered by status
+func (s *orderGRPCServer) GetOrdersByUser(ctx context.Context, req *pb.GetOrdersByUserRequest) (*pb.GetOrdersByUserResponse, error) {
+	start := time.Now()
+
+	if req.GetUserId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id must be positive")
+	}
+
+	var orders []*pb.Order
+	for _, order := range toProtoOrders(s.store.GetOrdersByUser(int(req.GetUserId()))) {
+		if req.GetStatusFilter() != pb.OrderStatus_ORDER_STATUS_UNKNOWN && order.Status != req.GetStatusFilter() {
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	total := len(orders)
+	if limit := int(req.GetLimit()); limit > 0 && limit < total {
+		orders = orders[:limit]
+	}
+
+	return &pb.GetOrdersByUserResponse{
+		Orders:     orders,
+		TotalCount: int32(total),
+		Metadata:   responseMetadata(ctx, start),
+	}, nil
+}
+
+// HealthCheck reports the readiness of the service and each of its checks
+func (s *orderGRPCServer) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	start := time.Now()
+
+	report := s.health.Readiness(ctx)
+	return &pb.HealthCheckResponse{
+		Status:   healthStatusToProto(report),
+		Message:  report.Status,
+		Details:  report.Details(),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// GetOrderMetrics summarises the orders created within the requested time range
+func (s *orderGRPCServer) GetOrderMetrics(ctx context.Context, req *pb.GetOrderMetricsRequest) (*pb.GetOrderMetricsResponse, error) {
+	start := time.Now()
+
+	query := OrderStatsQuery{Bucket: req.GetBucket()}
+	if req.StartTime != nil {
+		query.Start = req.StartTime.AsTime()
+	}
+	if req.EndTime != nil {
+		query.End = req.EndTime.AsTime()
+	}
+	report, err := s.store.OrderStats(query)
+	if errors.Is(err, ErrInvalidQuery) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "order metrics: %v", err)
+	}
+
+	resp := &pb.GetOrderMetricsResponse{
+		Metrics:  toProtoMetrics(report.Summary),
+		Metadata: responseMetadata(ctx, start),
+	}
+	for _, bucket := range report.Buckets {
+		resp.Buckets = append(resp.Buckets, toProtoMetrics(bucket))
+	}
+	return resp, nil
+}
+
+// storeError converts an OrderStore error into a gRPC status
+func storeError(err error, orderID int64) error {
+	if errors.Is(err, ErrOrderNotFound) {
+		return status.Errorf(codes.NotFound, "order %d not found", orderID)
+	}
+	if errors.Is(err, ErrInvalidShipping) || errors.Is(err, ErrInvalidCancelReason) || errors.Is(err, ErrCancelNoteTooLong) {
+		return status.Errorf(codes.InvalidArgument, "order %d: %v", orderID, err)
+	}
+	if errors.Is(err, ErrInvalidTransition) {
+		return status.Errorf(codes.FailedPrecondition, "order %d: %v", orderID, err)
+	}
+	return status.Errorf(codes.Internal, "order %d: %v", orderID, err)
+}
+
+// grpcActor identifies the caller of a gRPC request
+func grpcActor(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if actors := md.Get(actorMetadataKey); len(actors) > 0 && actors[0] != "" {
+			return actors[0]
+		}
+	}
+	return anonymousActor
+}
+
+// toProtoOrder converts a store order into its protobuf representation
+func toProtoOrder(order *Order) *pb.Order {
+	out := &pb.Order{
+		Id:          int64(order.ID),
+		UserId:      int64(order.UserID),
+		TotalAmount: order.Total.Float64(),
+		Total:       toProtoMoney(order.Total),
+		Status:      orderStatusToProto[order.Status],
+		Items:       make([]*pb.OrderItem, 0, len(order.Items)),
+
+		PaymentStatus: paymentStatusToProto[order.PaymentStatus],
+		Payment:       toProtoPayment(order),
+		Shipping:      toProtoShipping(order.Shipping),
+		Cancellation:  toProtoCancellation(order.Cancellation),
+	}
+	for _, item := range order.Items {
+		out.Items = append(out.Items, &pb.OrderItem{
+			Id:          int64(item.ID),
+			ProductId:   item.ProductID,
+			ProductName: item.Product,
+			Quantity:    int32(item.Quantity),
+			UnitPrice:   item.UnitPrice.Float64(),
+			TotalPrice:  item.TotalPrice.Float64(),
+			Attributes:  item.Attributes,
+			UnitAmount:  toProtoMoney(item.UnitPrice),
+			TotalAmount: toProtoMoney(item.TotalPrice),
+		})
+	}
+	// Clients predating items read single-item orders from the flat fields
+	if len(order.Items) == 1 {
+		out.ProductName = order.Items[0].Product
+		out.Quantity = int32(order.Items[0].Quantity)
+		out.Price = order.Items[0].UnitPrice.Float64()
+	}
+	if created, err := time.Parse(time.RFC3339, order.Created); err == nil {
+		out.CreatedAt = timestamppb.New(created)
+		out.UpdatedAt = timestamppb.New(created)
+	}
+	return out
+}
+
+// toProtoMetrics converts order stats into their protobuf representation
+func toProtoMetrics(stats OrderStats) *pb.OrderMetrics {
+	out := &pb.OrderMetrics{
+		TotalOrders:     int32(stats.TotalOrders),
+		PendingOrders:   int32(stats.PendingOrders),
+		CompletedOrders: int32(stats.CompletedOrders),
+		CancelledOrders: int32(stats.CancelledOrders),
+	}
+	for i, revenue := range stats.Revenue {
+		out.Revenue = append(out.Revenue, toProtoMoney(revenue))
+		out.AverageOrderValues = append(out.AverageOrderValues, toProtoMoney(stats.AverageOrderValue[i]))
+	}
+	// The doubles cannot add up amounts in different currencies
+	if len(stats.Revenue) == 1 {
+		out.TotalRevenue = stats.Revenue[0].Float64()
+		out.AverageOrderValue = stats.AverageOrderValue[0].Float64()
+		out.Currency = stats.Revenue[0].Currency
+	}
+	if t, err := time.Parse(time.RFC3339, stats.Start); err == nil {
+		out.StartTime = timestamppb.New(t)
+	}
+	if t, err := time.Parse(time.RFC3339, stats.End); err == nil {
+		out.EndTime = timestamppb.New(t)
+	}
+	return out
+}
+
+// toProtoMoney converts an exact amount into its protobuf representation
+func toProtoMoney(m Money) *pb.Money {
+	return &pb.Money{CurrencyCode: m.Currency, MinorUnits: m.Amount}
+}
+
+// toProtoPayment converts the payment of an order, if any
+func toProtoPayment(order *Order) *pb.PaymentInfo {
+	info := order.Payment
+	if info == nil {
+		return nil
+	}
+	out := &pb.PaymentInfo{
+		PaymentId:      info.ID,
+		Method:         paymentMethodToProto[info.Method],
+		Status:         paymentStatusToProto[order.PaymentStatus],
+		Amount:         info.Amount.Float64(),
+		Currency:       info.Amount.Currency,
+		TransactionRef: info.TransactionRef,
+		ExactAmount:    toProtoMoney(info.Amount),
+	}
+	// processed_at is the time of the latest provider operation
+	for _, at := range []string{info.RefundedAt, info.CapturedAt, info.AuthorizedAt} {
+		if processed, err := time.Parse(time.RFC3339, at); err == nil {
+			out.ProcessedAt = timestamppb.New(processed)
+			break
+		}
+	}
+	return out
+}
+
+// toProtoShipping converts the shipping details of an order, if any
+func toProtoShipping(info *ShippingInfo) *pb.ShippingInfo {
+	if info == nil {
+		return nil
+	}
+	out := &pb.ShippingInfo{
+		Address:        info.Address,
+		City:           info.City,
+		PostalCode:     info.PostalCode,
+		Country:        info.Country,
+		Method:         shippingMethodToProto[info.Method],
+		ShippingCost:   info.Cost.Float64(),
+		TrackingNumber: info.TrackingNumber,
+		ExactCost:      toProtoMoney(info.Cost),
+	}
+	if estimated, err := time.Parse(time.RFC3339, info.EstimatedDelivery); err == nil {
+		out.EstimatedDelivery = timestamppb.New(estimated)
+	}
+	return out
+}
+
+// toProtoCancellation converts the cancellation of an order, if any
+func toProtoCancellation(info *Cancellation) *pb.CancellationInfo {
+	if info == nil {
+		return nil
+	}
+	out := &pb.CancellationInfo{
+		ReasonCode:  info.Reason,
+		Note:        info.Note,
+		CancelledBy: info.Actor,
+	}
+	if at, err := time.Parse(time.RFC3339, info.At); err == nil {
+		out.CancelledAt = timestamppb.New(at)
+	}
+	return out
+}
+
+// fromProtoShipping reads the requested shipping details of a create request.
+// The cost, estimated delivery and tracking number are ignored.
+func fromProtoShipping(info *pb.ShippingInfo) (*ShippingInfo, error) {
+	if info == nil {
+		return nil, nil
+	}
+	method, ok := shippingMethodFromProto[info.GetMethod()]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown method %s", ErrInvalidShipping, info.GetMethod())
+	}
+	return &ShippingInfo{
+		Address:    info.GetAddress(),
+		City:       info.GetCity(),
+		PostalCode: info.GetPostalCode(),
+		Country:    info.GetCountry(),
+		Method:     method,
+	}, nil
+}
+
+// fromProtoItems reads the line items of a create request, falling back to
+// the single-product fields when no items are given. Exact unit_amount
+// prices must be in the request currency.
+func fromProtoItems(req *pb.CreateOrderRequest) ([]OrderItem, error) {
+	currency := req.GetCurrency()
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.GetItems()) == 0 {
+		price, err := moneyFromFloat(req.GetPrice(), currency)
+		if err != nil {
+			return nil, err
+		}
+		return singleItem(req.GetProductName(), int(req.GetQuantity()), price), nil
+	}
+
+	items := make([]OrderItem, 0, len(req.GetItems()))
+	for i, item := range req.GetItems() {
+		price, err := moneyFromFloat(item.GetUnitPrice(), currency)
+		if exact := item.GetUnitAmount(); exact != nil {
+			price, err = NewMoney(exact.GetMinorUnits(), exact.GetCurrencyCode())
+			if err == nil && price.Currency != currency {
+				err = fmt.Errorf("%w: item %d priced in %s for an order in %s", ErrCurrencyMismatch, i+1, price.Currency, currency)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, OrderItem{
+			ProductID:  item.GetProductId(),
+			Product:    item.GetProductName(),
+			Quantity:   int(item.GetQuantity()),
+			UnitPrice:  price,
+			Attributes: item.GetAttributes(),
+		})
+	}
+	return items, nil
+}
+
+// toProtoOrders converts store orders into protobuf orders ordered by ID
+func toProtoOrders(orders []*Order) []*pb.Order {
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+
+	out := make([]*pb.Order, 0, len(orders))
+	for _, order := range orders {
+		out = append(out, toProtoOrder(order))
+	}
+	return out
+}
+
+// responseMetadata builds the metadata attached to every gRPC response
+func responseMetadata(ctx context.Context, start time.Time) *pb.ResponseMetadata {
+	return &pb.ResponseMetadata{
+		RequestId:        httpx.GRPCRequestID(ctx),
+		Timestamp:        timestamppb.Now(),
+		ProcessingTimeMs: int32(time.Since(start).Milliseconds()),
+		Version:          serviceVersion,
+	}
+}
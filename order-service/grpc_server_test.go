@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"order-service/internal/userclient"
+	pb "order-service/proto"
+)
+
+// newTestGRPCClient starts an in-process gRPC server backed by store
+func newTestGRPCClient(t *testing.T, store *OrderStore) pb.OrderServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(store, httpx.NewHealth("order-service", httpx.HealthConfig{}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewOrderServiceClient(conn)
+}
+
+func TestOrderStatusMappingRoundTrip(t *testing.T) {
+	for name, enum := range orderStatusToProto {
+		if orderStatusFromProto[enum] != name {
+			t.Errorf("Status %s does not round-trip through %v", name, enum)
+		}
+	}
+	if _, ok := orderStatusFromProto[pb.OrderStatus_ORDER_STATUS_UNKNOWN]; ok {
+		t.Error("Expected ORDER_STATUS_UNKNOWN to have no REST status")
+	}
+}
+
+func TestGRPCGetOrder(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+
+	resp, err := client.GetOrder(context.Background(), &pb.GetOrderRequest{OrderId: 2})
+	if err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if resp.Order.ProductName != "Mouse" || resp.Order.TotalAmount != 50.00 {
+		t.Errorf("Unexpected order: %v", resp.Order)
+	}
+	if resp.Order.Status != pb.OrderStatus_ORDER_STATUS_PENDING {
+		t.Errorf("Expected PENDING, got %v", resp.Order.Status)
+	}
+	if resp.Metadata == nil || resp.Metadata.RequestId == "" || resp.Metadata.Version != serviceVersion {
+		t.Errorf("Unexpected metadata: %v", resp.Metadata)
+	}
+}
+
+func TestGRPCGetOrder_NotFound(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+
+	_, err := client.GetOrder(context.Background(), &pb.GetOrderRequest{OrderId: 999})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestGRPCListOrders(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+
+	resp, err := client.ListOrders(context.Background(), &pb.ListOrdersRequest{})
+	if err != nil {
+		t.Fatalf("ListOrders failed: %v", err)
+	}
+	if resp.TotalCount != 2 || resp.Orders[0].Id != 1 || resp.Orders[1].Id != 2 {
+		t.Errorf("Expected 2 orders ordered by ID, got %v", resp.Orders)
+	}
+}
+
+func TestGRPCListOrdersFilterAndSort(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+	ctx := context.Background()
+
+	resp, err := client.ListOrders(ctx, &pb.ListOrdersRequest{PageSize: 1, SortBy: "amount", SortOrder: pb.SortOrder_SORT_ORDER_ASC})
+	if err != nil {
+		t.Fatalf("ListOrders failed: %v", err)
+	}
+	if len(resp.Orders) != 1 || resp.Orders[0].Id != 2 || resp.NextPageToken == "" {
+		t.Fatalf("Expected the cheaper order first with a next page, got %v", resp)
+	}
+	resp, err = client.ListOrders(ctx, &pb.ListOrdersRequest{PageSize: 1, PageToken: resp.NextPageToken, SortBy: "amount", SortOrder: pb.SortOrder_SORT_ORDER_ASC})
+	if err != nil {
+		t.Fatalf("ListOrders failed: %v", err)
+	}
+	if len(resp.Orders) != 1 || resp.Orders[0].Id != 1 {
+		t.Errorf("Expected the dearer order on the second page, got %v", resp.Orders)
+	}
+
+	resp, err = client.ListOrders(ctx, &pb.ListOrdersRequest{Filter: &pb.OrderFilter{
+		UserId:        2,
+		Status:        pb.OrderStatus_ORDER_STATUS_PENDING,
+		PaymentStatus: pb.PaymentStatus_PAYMENT_STATUS_PENDING,
+		CreatedAfter:  timestamppb.New(time.Now().Add(-time.Hour)),
+	}})
+	if err != nil {
+		t.Fatalf("ListOrders failed: %v", err)
+	}
+	if resp.TotalCount != 1 || resp.Orders[0].UserId != 2 {
+		t.Errorf("Expected only the order of user 2, got %v", resp.Orders)
+	}
+
+	resp, err = client.ListOrders(ctx, &pb.ListOrdersRequest{Filter: &pb.OrderFilter{Status: pb.OrderStatus_ORDER_STATUS_SHIPPED}})
+	if err != nil {
+		t.Fatalf("ListOrders failed: %v", err)
+	}
+	if resp.TotalCount != 0 {
+		t.Errorf("Expected no shipped orders, got %v", resp.Orders)
+	}
+
+	resp, err = client.ListOrders(ctx, &pb.ListOrdersRequest{Filter: &pb.OrderFilter{Currency: "USD", MinAmount: 100}})
+	if err != nil {
+		t.Fatalf("ListOrders failed: %v", err)
+	}
+	if resp.TotalCount != 1 || resp.Orders[0].Id != 1 {
+		t.Errorf("Expected only the order of at least 100 USD, got %v", resp.Orders)
+	}
+	_, err = client.ListOrders(ctx, &pb.ListOrdersRequest{Filter: &pb.OrderFilter{MinAmount: 100}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an amount without currency, got %v", err)
+	}
+
+	_, err = client.ListOrders(ctx, &pb.ListOrdersRequest{SortBy: "colour"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an unknown sort field, got %v", err)
+	}
+}
+
+func TestGRPCCreateOrder(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId:      1,
+		ProductName: "Keyboard",
+		Quantity:    2,
+		Price:       45.5,
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+	if resp.Order.TotalAmount != 91 {
+		t.Errorf("Expected total 91, got %f", resp.Order.TotalAmount)
+	}
+	if _, exists := store.GetOrder(int(resp.Order.Id)); !exists {
+		t.Error("Expected order to be stored in OrderStore")
+	}
+}
+
+func TestGRPCCreateOrderWithItems(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId: 1,
+		Items: []*pb.OrderItem{
+			{ProductId: "kb-1", ProductName: "Keyboard", Quantity: 1, UnitPrice: 45.5},
+			{ProductName: "Keycap", Quantity: 3, UnitPrice: 0.1, TotalPrice: 1000},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+	if len(resp.Order.Items) != 2 || resp.Order.Items[1].TotalPrice != 0.3 {
+		t.Fatalf("Expected two priced items, got %v", resp.Order.Items)
+	}
+	if resp.Order.TotalAmount != 45.8 || resp.Order.ProductName != "" {
+		t.Errorf("Expected total 45.8 and no single product, got %v and %q", resp.Order.TotalAmount, resp.Order.ProductName)
+	}
+	if total := resp.Order.Total; total.GetMinorUnits() != 4580 || total.GetCurrencyCode() != "USD" {
+		t.Errorf("Expected exact total of 4580 USD minor units, got %v", total)
+	}
+
+	_, err = client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId: 1,
+		Items:  []*pb.OrderItem{{ProductName: "Keycap", Quantity: 1, UnitPrice: 0.125}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for sub-cent price, got %v", err)
+	}
+}
+
+func TestGRPCCreateOrderExactAmounts(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId:   1,
+		Currency: "JPY",
+		Items: []*pb.OrderItem{
+			{ProductName: "Tea", Quantity: 2, UnitAmount: &pb.Money{CurrencyCode: "JPY", MinorUnits: 1500}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+	if total := resp.Order.Total; total.GetMinorUnits() != 3000 || total.GetCurrencyCode() != "JPY" {
+		t.Errorf("Expected 3000 JPY, got %v", total)
+	}
+	if item := resp.Order.Items[0]; item.UnitAmount.GetMinorUnits() != 1500 || item.UnitPrice != 1500 {
+		t.Errorf("Expected unit price 1500 JPY, got %v", item)
+	}
+
+	for _, req := range []*pb.CreateOrderRequest{
+		{UserId: 1, Currency: "XYZ", ProductName: "Tea", Quantity: 1, Price: 1},
+		{UserId: 1, Currency: "EUR", Items: []*pb.OrderItem{
+			{ProductName: "Tea", Quantity: 1, UnitAmount: &pb.Money{CurrencyCode: "USD", MinorUnits: 100}},
+		}},
+	} {
+		if _, err := client.CreateOrder(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument for %v, got %v", req, err)
+		}
+	}
+}
+
+func TestGRPCCreateOrder_Invalid(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+
+	_, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{UserId: 1, ProductName: "Keyboard"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGRPCCreateOrder_UnknownUser(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: userclient.ErrNotFound}
+	client := newTestGRPCClient(t, store)
+
+	_, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId:      42,
+		ProductName: "Keyboard",
+		Quantity:    1,
+		Price:       45.5,
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestGRPCCreateOrder_SuspendedUser(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1, Status: userclient.StatusSuspended}}
+	client := newTestGRPCClient(t, store)
+
+	_, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId:      1,
+		ProductName: "Keyboard",
+		Quantity:    1,
+		Price:       45.5,
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied, got %v", err)
+	}
+}
+
+func TestGRPCUpdateOrderStatus(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+	client := newTestGRPCClient(t, store)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), actorMetadataKey, "warehouse")
+	resp, err := client.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{
+		OrderId:   1,
+		NewStatus: pb.OrderStatus_ORDER_STATUS_PROCESSING,
+		Reason:    "picked",
+	})
+	if err != nil {
+		t.Fatalf("UpdateOrderStatus failed: %v", err)
+	}
+	if resp.Order.Status != pb.OrderStatus_ORDER_STATUS_PROCESSING {
+		t.Errorf("Expected PROCESSING, got %v", resp.Order.Status)
+	}
+	if p := resp.Order.Payment; resp.Order.PaymentStatus != pb.PaymentStatus_PAYMENT_STATUS_COMPLETED || p.GetMethod() != pb.PaymentMethod_PAYMENT_METHOD_CREDIT_CARD || p.GetExactAmount().GetMinorUnits() != 99999 {
+		t.Errorf("Expected the captured payment, got %v and %v", resp.Order.PaymentStatus, p)
+	}
+	if order, _ := store.GetOrder(1); order.Status != "processing" {
+		t.Errorf("Expected stored status 'processing', got %s", order.Status)
+	}
+	history, _ := store.GetOrderHistory(1)
+	if len(history) != 2 || history[1].Actor != "warehouse" || history[1].Reason != "picked" {
+		t.Errorf("Expected the change to be recorded with its actor, got %+v", history)
+	}
+
+	_, err = client.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{
+		OrderId:   1,
+		NewStatus: pb.OrderStatus_ORDER_STATUS_PENDING,
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition for a backwards transition, got %v", err)
+	}
+
+	_, err = client.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{OrderId: 1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for unknown status, got %v", err)
+	}
+
+	_, err = client.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{
+		OrderId:   999,
+		NewStatus: pb.OrderStatus_ORDER_STATUS_SHIPPED,
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestGRPCShipping(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	client := newTestGRPCClient(t, store)
+
+	created, err := client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId: 1,
+		Items:  []*pb.OrderItem{{ProductName: "Lamp", Quantity: 2, UnitPrice: 25}},
+		Shipping: &pb.ShippingInfo{
+			Address: "1 Main St",
+			City:    "Springfield",
+			Country: "US",
+			Method:  pb.ShippingMethod_SHIPPING_METHOD_EXPRESS,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+	shipping := created.Order.Shipping
+	if shipping.GetMethod() != pb.ShippingMethod_SHIPPING_METHOD_EXPRESS || shipping.GetExactCost().GetMinorUnits() != 1599 || shipping.GetEstimatedDelivery() == nil {
+		t.Errorf("Expected priced express shipping, got %v", shipping)
+	}
+	if created.Order.Total.GetMinorUnits() != 6599 {
+		t.Errorf("Expected the shipping cost in the total, got %v", created.Order.Total)
+	}
+
+	_, err = client.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId:   1,
+		Items:    []*pb.OrderItem{{ProductName: "Lamp", Quantity: 1, UnitPrice: 25}},
+		Shipping: &pb.ShippingInfo{Method: pb.ShippingMethod_SHIPPING_METHOD_STANDARD},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without an address, got %v", err)
+	}
+
+	id := created.Order.Id
+	payOrder(t, store, int(id))
+	if _, err := store.TransitionOrder(int(id), StatusProcessing, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{
+		OrderId:   id,
+		NewStatus: pb.OrderStatus_ORDER_STATUS_SHIPPED,
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected FailedPrecondition without a tracking number, got %v", err)
+	}
+	_, err = client.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{
+		OrderId:        id,
+		NewStatus:      pb.OrderStatus_ORDER_STATUS_SHIPPED,
+		TrackingNumber: "bad tracking",
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a malformed tracking number, got %v", err)
+	}
+	resp, err := client.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{
+		OrderId:        id,
+		NewStatus:      pb.OrderStatus_ORDER_STATUS_SHIPPED,
+		TrackingNumber: "1Z999AA10123456784",
+	})
+	if err != nil {
+		t.Fatalf("UpdateOrderStatus failed: %v", err)
+	}
+	if resp.Order.Status != pb.OrderStatus_ORDER_STATUS_SHIPPED || resp.Order.Shipping.GetTrackingNumber() != "1Z999AA10123456784" {
+		t.Errorf("Expected a shipped order with its tracking number, got %v", resp.Order)
+	}
+}
+
+func TestGRPCCancelOrder(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+
+	resp, err := client.CancelOrder(context.Background(), &pb.CancelOrderRequest{OrderId: 1, Reason: "changed mind"})
+	if err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	if !resp.Success || resp.Order.Status != pb.OrderStatus_ORDER_STATUS_CANCELLED {
+		t.Errorf("Unexpected cancel response: %v", resp)
+	}
+	if c := resp.Order.Cancellation; c.GetReasonCode() != CancelReasonOther || c.GetNote() != "changed mind" || c.GetCancelledAt() == nil {
+		t.Errorf("Expected the cancellation to be recorded, got %v", c)
+	}
+
+	resp, err = client.CancelOrder(context.Background(), &pb.CancelOrderRequest{OrderId: 2, ReasonCode: CancelReasonOutOfStock})
+	if err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	if resp.Order.Cancellation.GetReasonCode() != CancelReasonOutOfStock {
+		t.Errorf("Expected reason code out_of_stock, got %v", resp.Order.Cancellation)
+	}
+
+	_, err = client.CancelOrder(context.Background(), &pb.CancelOrderRequest{OrderId: 2, ReasonCode: "bored"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an unknown reason code, got %v", err)
+	}
+}
+
+func TestGRPCGetOrdersByUser(t *testing.T) {
+	store := NewOrderStore()
+	store.CreateOrder(1, singleItem("Monitor", 1, usd(19999)))
+	payOrder(t, store, 1)
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.GetOrdersByUser(context.Background(), &pb.GetOrdersByUserRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("GetOrdersByUser failed: %v", err)
+	}
+	if resp.TotalCount != 2 {
+		t.Errorf("Expected 2 orders for user 1, got %d", resp.TotalCount)
+	}
+
+	resp, err = client.GetOrdersByUser(context.Background(), &pb.GetOrdersByUserRequest{
+		UserId:       1,
+		StatusFilter: pb.OrderStatus_ORDER_STATUS_PENDING,
+	})
+	if err != nil {
+		t.Fatalf("GetOrdersByUser failed: %v", err)
+	}
+	if resp.TotalCount != 1 || resp.Orders[0].ProductName != "Monitor" {
+		t.Errorf("Expected only the pending order, got %v", resp.Orders)
+	}
+
+	resp, err = client.GetOrdersByUser(context.Background(), &pb.GetOrdersByUserRequest{UserId: 1, Limit: 1})
+	if err != nil {
+		t.Fatalf("GetOrdersByUser failed: %v", err)
+	}
+	if resp.TotalCount != 2 || len(resp.Orders) != 1 {
+		t.Errorf("Expected 1 of 2 orders, got %d of %d", len(resp.Orders), resp.TotalCount)
+	}
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	client := newTestGRPCClient(t, NewOrderStore())
+
+	resp, err := client.HealthCheck(context.Background(), &pb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("HealthCheck failed: %v", err)
+	}
+	if resp.Status != pb.HealthStatus_HEALTH_STATUS_SERVING {
+		t.Errorf("Expected SERVING, got %v", resp.Status)
+	}
+}
+
+func TestGRPCGetOrderMetrics(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+	store.UpdateOrderStatus(2, "cancelled")
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.GetOrderMetrics(context.Background(), &pb.GetOrderMetricsRequest{})
+	if err != nil {
+		t.Fatalf("GetOrderMetrics failed: %v", err)
+	}
+	m := resp.Metrics
+	if m.TotalOrders != 2 || m.PendingOrders != 0 || m.CancelledOrders != 1 {
+		t.Errorf("Unexpected counts: %v", m)
+	}
+	if m.TotalRevenue != 999.99 || m.AverageOrderValue != 999.99 || m.Currency != "USD" {
+		t.Errorf("Unexpected revenue: %v", m)
+	}
+	if len(m.Revenue) != 1 || m.Revenue[0].GetMinorUnits() != 99999 || m.Revenue[0].GetCurrencyCode() != "USD" {
+		t.Errorf("Expected exact revenue in USD, got %v", m.Revenue)
+	}
+
+	now := time.Now()
+	resp, err = client.GetOrderMetrics(context.Background(), &pb.GetOrderMetricsRequest{
+		StartTime: timestamppb.New(now.Add(-time.Hour)),
+		EndTime:   timestamppb.New(now.Add(time.Hour)),
+		Bucket:    BucketHour,
+	})
+	if err != nil {
+		t.Fatalf("GetOrderMetrics failed: %v", err)
+	}
+	total := int32(0)
+	for _, bucket := range resp.Buckets {
+		total += bucket.TotalOrders
+	}
+	if len(resp.Buckets) < 2 || total != 2 {
+		t.Errorf("Expected both orders across hourly buckets, got %v", resp.Buckets)
+	}
+
+	_, err = client.GetOrderMetrics(context.Background(), &pb.GetOrderMetricsRequest{Bucket: BucketDay})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for buckets without a range, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	pb "order-service/proto"
+)
+
+// userPinger is implemented by user-service clients that can check whether
+// user-service is ready
+type userPinger interface {
+	Ping(ctx context.Context) error
+}
+
+// newServiceHealth creates the health checks of order-service. The store is
+// required for readiness. user-service is only reported, so that an outage
+// there does not take order-service out of rotation too, unless usersRequired
+// is set.
+func newServiceHealth(store *OrderStore, config httpx.HealthConfig, usersRequired bool) *httpx.Health {
+	health := httpx.NewHealth("order-service", config)
+	health.Register("store", httpx.Readiness, httpx.CheckerFunc(store.CheckHealth))
+	if users, ok := store.users.(userPinger); ok {
+		kind := httpx.Optional
+		if usersRequired {
+			kind = httpx.Readiness
+		}
+		health.Register("user-service", kind, httpx.CheckerFunc(users.Ping))
+	}
+	return health
+}
+
+// healthStatusToProto maps a readiness report onto the proto enum; degraded
+// services are still serving
+func healthStatusToProto(report httpx.HealthReport) pb.HealthStatus {
+	if report.Status == httpx.StatusUnhealthy {
+		return pb.HealthStatus_HEALTH_STATUS_NOT_SERVING
+	}
+	return pb.HealthStatus_HEALTH_STATUS_SERVING
+}
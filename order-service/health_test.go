@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"order-service/internal/userclient"
+	pb "order-service/proto"
+)
+
+func TestServiceHealthUserService(t *testing.T) {
+	ready := true
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer users.Close()
+
+	store := NewOrderStore()
+	store.users = userclient.New(users.URL, time.Second)
+	health := newServiceHealth(store, httpx.HealthConfig{}, false)
+	if report := health.Readiness(context.Background()); report.Status != httpx.StatusHealthy || len(report.Checks) != 2 {
+		t.Errorf("Expected store and user-service to be healthy, got %+v", report)
+	}
+
+	ready = false
+	if report := health.Readiness(context.Background()); report.Status != httpx.StatusDegraded {
+		t.Errorf("Expected readiness to be degraded without user-service, got %+v", report)
+	}
+	if report := health.Liveness(context.Background()); report.Status != httpx.StatusHealthy {
+		t.Errorf("Expected liveness not to depend on user-service, got %+v", report)
+	}
+
+	health = newServiceHealth(store, httpx.HealthConfig{}, true)
+	if report := health.Readiness(context.Background()); report.Status != httpx.StatusUnhealthy {
+		t.Errorf("Expected readiness to fail without a required user-service, got %+v", report)
+	}
+}
+
+func TestOrderStoreCheckHealth(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestOrderStore(t, dir, 100)
+	defer store.Close()
+
+	if err := store.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("Expected a healthy store, got %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CheckHealth(context.Background()); err == nil {
+		t.Error("Expected a store whose log was removed to be unhealthy")
+	}
+
+	store.mutex.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := store.CheckHealth(ctx)
+	store.mutex.Unlock()
+	if err == nil {
+		t.Error("Expected a store whose lock is held to be unhealthy")
+	}
+}
+
+func TestGRPCHealthCheckReportsChecks(t *testing.T) {
+	health := httpx.NewHealth("order-service", httpx.HealthConfig{})
+	health.Register("store", httpx.Readiness, httpx.CheckerFunc(func(ctx context.Context) error { return os.ErrClosed }))
+	srv := &orderGRPCServer{store: NewOrderStore(), health: health}
+
+	resp, err := srv.HealthCheck(context.Background(), &pb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != pb.HealthStatus_HEALTH_STATUS_NOT_SERVING || resp.Details["store"] != "unhealthy: file already closed" {
+		t.Errorf("Expected NOT_SERVING with the store error, got %v %v", resp.Status, resp.Details)
+	}
+}
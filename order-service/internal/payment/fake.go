@@ -0,0 +1,76 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// Fake states of a payment
+const (
+	fakeAuthorized = "authorized"
+	fakeCaptured   = "captured"
+	fakeRefunded   = "refunded"
+)
+
+// Fake is an in-process Provider. It approves every authorization up to
+// Limit minor units, or any amount when Limit is zero. Payment IDs carry a
+// random prefix per Fake so that IDs issued before a restart are never
+// issued again.
+type Fake struct {
+	Limit int64
+
+	mutex    sync.Mutex
+	prefix   string
+	nextID   int
+	payments map[string]string
+}
+
+// NewFake creates a fake provider with no limit
+func NewFake() *Fake {
+	var b [4]byte
+	rand.Read(b[:])
+	return &Fake{prefix: "fake-" + hex.EncodeToString(b[:]), nextID: 1, payments: make(map[string]string)}
+}
+
+// Authorize holds the requested amount unless it exceeds Limit
+func (f *Fake) Authorize(ctx context.Context, req Request) (*Result, error) {
+	if f.Limit > 0 && req.Amount > f.Limit {
+		return nil, fmt.Errorf("%w: %d %s exceeds the limit", ErrDeclined, req.Amount, req.Currency)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	id := fmt.Sprintf("%s-pay-%d", f.prefix, f.nextID)
+	f.nextID++
+	f.payments[id] = fakeAuthorized
+	return &Result{PaymentID: id, TransactionRef: id + "-auth"}, nil
+}
+
+// Capture takes an authorized payment
+func (f *Fake) Capture(ctx context.Context, paymentID string) (*Result, error) {
+	return f.move(paymentID, fakeAuthorized, fakeCaptured, "capture")
+}
+
+// Refund returns a captured payment
+func (f *Fake) Refund(ctx context.Context, paymentID string) (*Result, error) {
+	return f.move(paymentID, fakeCaptured, fakeRefunded, "refund")
+}
+
+func (f *Fake) move(paymentID, from, to, op string) (*Result, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	state, ok := f.payments[paymentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, paymentID)
+	}
+	if state != from {
+		return nil, fmt.Errorf("%w: cannot %s a payment that is %s", ErrInvalidState, op, state)
+	}
+	f.payments[paymentID] = to
+	return &Result{PaymentID: paymentID, TransactionRef: paymentID + "-" + op}, nil
+}
@@ -0,0 +1,83 @@
+// Package payment defines the interface order-service uses to take payments
+// and an in-process fake provider for tests and local runs
+package payment
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+)
+
+// Payment methods, mirroring PaymentMethod in order.proto
+const (
+	MethodCreditCard     = "credit_card"
+	MethodDebitCard      = "debit_card"
+	MethodBankTransfer   = "bank_transfer"
+	MethodDigitalWallet  = "digital_wallet"
+	MethodCryptocurrency = "cryptocurrency"
+)
+
+// Methods lists every supported payment method
+var Methods = map[string]bool{
+	MethodCreditCard:     true,
+	MethodDebitCard:      true,
+	MethodBankTransfer:   true,
+	MethodDigitalWallet:  true,
+	MethodCryptocurrency: true,
+}
+
+var (
+	// ErrDeclined is returned when the provider refuses an authorization
+	ErrDeclined = errors.New("payment declined")
+	// ErrNotFound is returned for payment IDs the provider does not know
+	ErrNotFound = errors.New("payment not found")
+	// ErrInvalidState is returned when a payment cannot move to the requested state
+	ErrInvalidState = errors.New("invalid payment state")
+)
+
+// Request asks a provider to hold funds for an order. Amount is in the minor
+// units of Currency.
+type Request struct {
+	OrderID  int
+	Amount   int64
+	Currency string
+	Method   string
+}
+
+// Result identifies a payment operation at the provider
+type Result struct {
+	PaymentID      string
+	TransactionRef string
+}
+
+// Provider authorizes, captures and refunds payments. Authorize holds the
+// funds, Capture takes them and Refund returns captured funds in full.
+type Provider interface {
+	Authorize(ctx context.Context, req Request) (*Result, error)
+	Capture(ctx context.Context, paymentID string) (*Result, error)
+	Refund(ctx context.Context, paymentID string) (*Result, error)
+}
+
+// NewFromEnv returns the provider named by PAYMENT_PROVIDER, which must be
+// set so that a deployment never takes fake payments by accident. Only the
+// fake provider is built in; PAYMENT_FAKE_LIMIT sets the largest amount it
+// authorizes.
+func NewFromEnv() (Provider, error) {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "":
+		return nil, errors.New(`PAYMENT_PROVIDER is required; set it to "fake" for local runs`)
+	case "fake":
+		fake := NewFake()
+		if value := os.Getenv("PAYMENT_FAKE_LIMIT"); value != "" {
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.New("invalid PAYMENT_FAKE_LIMIT: " + value)
+			}
+			fake.Limit = limit
+		}
+		return fake, nil
+	default:
+		return nil, errors.New("unknown PAYMENT_PROVIDER: " + name)
+	}
+}
@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFakeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	auth, err := fake.Authorize(ctx, Request{OrderID: 1, Amount: 999, Currency: "USD", Method: MethodCreditCard})
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	if _, err := fake.Refund(ctx, auth.PaymentID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Expected refund before capture to fail, got %v", err)
+	}
+	if _, err := fake.Capture(ctx, auth.PaymentID); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	if _, err := fake.Capture(ctx, auth.PaymentID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Expected second capture to fail, got %v", err)
+	}
+	refund, err := fake.Refund(ctx, auth.PaymentID)
+	if err != nil || refund.PaymentID != auth.PaymentID {
+		t.Fatalf("Refund failed: %v", err)
+	}
+}
+
+func TestFakeDeclinesAboveLimit(t *testing.T) {
+	fake := NewFake()
+	fake.Limit = 1000
+
+	if _, err := fake.Authorize(context.Background(), Request{Amount: 1001, Currency: "USD"}); !errors.Is(err, ErrDeclined) {
+		t.Errorf("Expected ErrDeclined, got %v", err)
+	}
+	if _, err := fake.Capture(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("PAYMENT_PROVIDER", "")
+	if _, err := NewFromEnv(); err == nil {
+		t.Error("Expected an unset provider to fail")
+	}
+
+	t.Setenv("PAYMENT_PROVIDER", "fake")
+	t.Setenv("PAYMENT_FAKE_LIMIT", "500")
+	provider, err := NewFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake, ok := provider.(*Fake); !ok || fake.Limit != 500 {
+		t.Errorf("Expected a fake provider limited to 500, got %#v", provider)
+	}
+
+	t.Setenv("PAYMENT_PROVIDER", "acme")
+	if _, err := NewFromEnv(); err == nil {
+		t.Error("Expected an unknown provider to fail")
+	}
+}
@@ -0,0 +1,117 @@
+package userclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is used when USER_SERVICE_URL is not set
+const DefaultBaseURL = "http://user-service:8080"
+
+// DefaultTimeout bounds every request made to user-service
+const DefaultTimeout = 5 * time.Second
+
+var (
+	// ErrNotFound is returned when user-service has no user with the requested ID
+	ErrNotFound = errors.New("user not found")
+	// ErrUnavailable is returned when user-service cannot be reached or fails
+	ErrUnavailable = errors.New("user service unavailable")
+)
+
+// User represents user data from user-service
+type User struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+}
+
+// StatusSuspended is the lifecycle status of users barred from placing orders
+const StatusSuspended = "suspended"
+
+// Client calls the user-service REST API over a shared connection pool
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a client for the user-service running at baseURL
+func New(baseURL string, timeout time.Duration) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 20
+	transport.IdleConnTimeout = 90 * time.Second
+
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		},
+	}
+}
+
+// NewFromEnv creates a client for the user-service named by USER_SERVICE_URL
+func NewFromEnv() *Client {
+	baseURL := os.Getenv("USER_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return New(baseURL, DefaultTimeout)
+}
+
+// GetUser fetches a user by ID
+func (c *Client) GetUser(ctx context.Context, id int) (*User, error) {
+	url := fmt.Sprintf("%s/users/%d", c.baseURL, id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: id %d", ErrNotFound, id)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("user service returned status %d", resp.StatusCode)
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("decode user %d: %w", id, err)
+	}
+
+	return &user, nil
+}
+
+// Ping checks that user-service is ready to serve requests
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/readyz", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package userclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/1" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"name":"John Doe","email":"john@example.com","status":"active"}`))
+	}))
+	defer srv.Close()
+
+	user, err := New(srv.URL+"/", time.Second).GetUser(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	if user.ID != 1 || user.Name != "John Doe" || user.Email != "john@example.com" || user.Status != "active" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+}
+
+func TestGetUser_NotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, time.Second).GetUser(context.Background(), 999)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetUser_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, time.Second).GetUser(context.Background(), 1)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestGetUser_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	_, err := New(url, time.Second).GetUser(context.Background(), 1)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestGetUser_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, 50*time.Millisecond).GetUser(context.Background(), 1)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("USER_SERVICE_URL", "http://users.internal:9000/")
+	if c := NewFromEnv(); c.baseURL != "http://users.internal:9000" {
+		t.Errorf("Expected base URL from env, got %s", c.baseURL)
+	}
+
+	t.Setenv("USER_SERVICE_URL", "")
+	if c := NewFromEnv(); c.baseURL != DefaultBaseURL {
+		t.Errorf("Expected default base URL, got %s", c.baseURL)
+	}
+}
+
+func TestPing(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/readyz" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, time.Second)
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Expected a ready user-service, got %v", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := c.Ping(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+// Package wal implements a crash-safe write-ahead log with snapshots.
+//
+// Every record is framed with its sequence number, length and CRC32 so that a
+// record torn by a crash can be detected and discarded on the next Open.
+// Snapshots are written to a temporary file and atomically renamed into place
+// before the log is truncated; records already covered by a snapshot are
+// skipped during replay, so a crash between those two steps is harmless.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	logFile      = "wal.log"
+	snapshotFile = "snapshot"
+	tempSuffix   = ".tmp"
+
+	// headerSize is the size of a frame header: seq (8), length (4), crc (4)
+	headerSize = 16
+	// maxRecordSize guards against allocating huge buffers for corrupt lengths
+	maxRecordSize = 64 << 20
+)
+
+// ErrCorruptSnapshot is returned when the snapshot file fails its checksum
+var ErrCorruptSnapshot = errors.New("wal: corrupt snapshot")
+
+// ErrCorruptLog is returned when a record fails its checksum but intact data
+// follows it, so it cannot be the torn end of the log
+var ErrCorruptLog = errors.New("wal: corrupt log")
+
+// errChecksum is returned by readFrame, along with the payload, for a frame
+// whose checksum does not match
+var errChecksum = errors.New("checksum mismatch")
+
+// frameLengthError is returned by readFrame for a length over maxRecordSize
+type frameLengthError struct {
+	length uint32
+}
+
+func (e *frameLengthError) Error() string {
+	return fmt.Sprintf("record length %d exceeds limit", e.length)
+}
+
+// Log is an append-only write-ahead log stored in a directory
+type Log struct {
+	dir     string
+	file    *os.File
+	mutex   sync.Mutex
+	seq     uint64
+	size    int64
+	pending int
+	// lastErr is the error of the last Append, if it failed
+	lastErr error
+}
+
+// Open opens the log stored in dir, creating it if needed. restore is called
+// with the latest snapshot, if any, and apply with every record written after
+// it, in order. A torn record at the end of the log is truncated away; a
+// corrupt record before the end fails with ErrCorruptLog.
+func Open(dir string, restore func(snapshot []byte) error, apply func(record []byte) error) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("wal: create directory: %w", err)
+	}
+	// A temporary snapshot means a crash happened before it was renamed
+	os.Remove(filepath.Join(dir, snapshotFile+tempSuffix))
+
+	l := &Log{dir: dir}
+
+	snapSeq, err := l.loadSnapshot(restore)
+	if err != nil {
+		return nil, err
+	}
+	l.seq = snapSeq
+
+	l.file, err = os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("wal: open log: %w", err)
+	}
+	if err := l.replay(snapSeq, apply); err != nil {
+		l.file.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// loadSnapshot restores the snapshot, if present, and returns its sequence
+func (l *Log) loadSnapshot(restore func([]byte) error) (uint64, error) {
+	f, err := os.Open(filepath.Join(l.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("wal: open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	seq, payload, err := readFrame(bufio.NewReader(f))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	if err := restore(payload); err != nil {
+		return 0, fmt.Errorf("wal: restore snapshot: %w", err)
+	}
+
+	return seq, nil
+}
+
+// replay applies every intact record newer than snapSeq and truncates the
+// log after the last intact record. Only the last frame may be torn; a bad
+// frame with more data after it fails with ErrCorruptLog and leaves the file
+// as it is.
+func (l *Log) replay(snapSeq uint64, apply func([]byte) error) error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("wal: stat log: %w", err)
+	}
+	reader := bufio.NewReader(l.file)
+	var offset int64
+
+	for {
+		seq, payload, err := readFrame(reader)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			// A crash can leave garbage in the frame being written, but never
+			// before a later frame
+			end := offset + headerSize + int64(len(payload))
+			var lengthErr *frameLengthError
+			if errors.As(err, &lengthErr) {
+				end = offset + headerSize + int64(lengthErr.length)
+			}
+			if end >= info.Size() {
+				break
+			}
+			return fmt.Errorf("%w: record at offset %d: %v", ErrCorruptLog, offset, err)
+		}
+		offset += int64(headerSize + len(payload))
+
+		if seq <= snapSeq {
+			continue
+		}
+		if err := apply(payload); err != nil {
+			return fmt.Errorf("wal: apply record %d: %w", seq, err)
+		}
+		l.seq = seq
+		l.pending++
+	}
+
+	if err := l.truncate(offset); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// truncate cuts the log file to size and positions writes at its end
+func (l *Log) truncate(size int64) error {
+	if err := l.file.Truncate(size); err != nil {
+		return fmt.Errorf("wal: truncate log: %w", err)
+	}
+	if _, err := l.file.Seek(size, io.SeekStart); err != nil {
+		return fmt.Errorf("wal: seek log: %w", err)
+	}
+	l.size = size
+	return nil
+}
+
+// Append durably writes a record to the log
+func (l *Log) Append(record []byte) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	frame := encodeFrame(l.seq+1, record)
+	if _, err := l.file.Write(frame); err != nil {
+		// Drop any partial frame so later records are not hidden behind it
+		l.truncate(l.size)
+		l.lastErr = fmt.Errorf("wal: write record: %w", err)
+		return l.lastErr
+	}
+	if err := l.file.Sync(); err != nil {
+		l.truncate(l.size)
+		l.lastErr = fmt.Errorf("wal: sync log: %w", err)
+		return l.lastErr
+	}
+
+	l.lastErr = nil
+	l.seq++
+	l.size += int64(len(frame))
+	l.pending++
+	return nil
+}
+
+// Ping reports whether the log can still be written: the last append must
+// have succeeded, and the open log file must still be the one in the directory
+func (l *Log) Ping() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.lastErr != nil {
+		return l.lastErr
+	}
+	open, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("wal: stat log: %w", err)
+	}
+	onDisk, err := os.Stat(filepath.Join(l.dir, logFile))
+	if err != nil {
+		return fmt.Errorf("wal: stat log: %w", err)
+	}
+	if !os.SameFile(open, onDisk) {
+		return errors.New("wal: log file was replaced")
+	}
+	return nil
+}
+
+// Pending returns the number of records written since the last snapshot
+func (l *Log) Pending() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.pending
+}
+
+// Snapshot atomically replaces the snapshot with state, which must reflect
+// every record appended so far, and then truncates the log
+func (l *Log) Snapshot(state []byte) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	tmpPath := filepath.Join(l.dir, snapshotFile+tempSuffix)
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("wal: create snapshot: %w", err)
+	}
+	if _, err := tmp.Write(encodeFrame(l.seq, state)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("wal: write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("wal: sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("wal: close snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(l.dir, snapshotFile)); err != nil {
+		return fmt.Errorf("wal: install snapshot: %w", err)
+	}
+	if err := syncDir(l.dir); err != nil {
+		return err
+	}
+
+	// Records up to l.seq are now covered by the snapshot
+	if err := l.truncate(0); err != nil {
+		return err
+	}
+	l.pending = 0
+	return l.file.Sync()
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.file.Close()
+}
+
+// encodeFrame prefixes payload with its sequence number, length and checksum
+func encodeFrame(seq uint64, payload []byte) []byte {
+	frame := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint64(frame[0:8], seq)
+	binary.BigEndian.PutUint32(frame[8:12], uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[12:16], checksum(frame[0:8], payload))
+	copy(frame[headerSize:], payload)
+	return frame
+}
+
+// readFrame reads and verifies a single frame
+func readFrame(r io.Reader) (uint64, []byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	seq := binary.BigEndian.Uint64(header[0:8])
+	length := binary.BigEndian.Uint32(header[8:12])
+	if length > maxRecordSize {
+		return 0, nil, &frameLengthError{length: length}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if checksum(header[0:8], payload) != binary.BigEndian.Uint32(header[12:16]) {
+		return seq, payload, errChecksum
+	}
+
+	return seq, payload, nil
+}
+
+// checksum covers both the sequence number and the payload
+func checksum(seq, payload []byte) uint32 {
+	crc := crc32.NewIEEE()
+	crc.Write(seq)
+	crc.Write(payload)
+	return crc.Sum32()
+}
+
+// syncDir flushes directory metadata so a rename survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("wal: open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("wal: sync directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package wal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// collector records what Open restores and replays
+type collector struct {
+	snapshot string
+	records  []string
+}
+
+func (c *collector) restore(snapshot []byte) error {
+	c.snapshot = string(snapshot)
+	return nil
+}
+
+func (c *collector) apply(record []byte) error {
+	c.records = append(c.records, string(record))
+	return nil
+}
+
+func openTestLog(t *testing.T, dir string) (*Log, *collector) {
+	t.Helper()
+	c := &collector{}
+	l, err := Open(dir, c.restore, c.apply)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return l, c
+}
+
+func appendAll(t *testing.T, l *Log, records ...string) {
+	t.Helper()
+	for _, r := range records {
+		if err := l.Append([]byte(r)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+}
+
+func TestReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "a", "b", "c")
+	l.Close()
+
+	l, c := openTestLog(t, dir)
+	defer l.Close()
+	if len(c.records) != 3 || c.records[0] != "a" || c.records[2] != "c" {
+		t.Errorf("Unexpected replay: %v", c.records)
+	}
+	if l.Pending() != 3 {
+		t.Errorf("Expected 3 pending records, got %d", l.Pending())
+	}
+}
+
+func TestTornWriteIsDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "first", "second")
+	l.Close()
+
+	// Simulate a crash halfway through writing a third record
+	frame := encodeFrame(3, []byte("third record"))
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(frame[:len(frame)/2])
+	f.Close()
+
+	l, c := openTestLog(t, dir)
+	if len(c.records) != 2 {
+		t.Fatalf("Expected 2 intact records, got %v", c.records)
+	}
+	appendAll(t, l, "after crash")
+	l.Close()
+
+	l, c = openTestLog(t, dir)
+	defer l.Close()
+	if len(c.records) != 3 || c.records[2] != "after crash" {
+		t.Errorf("Expected records written after recovery to replay, got %v", c.records)
+	}
+}
+
+func TestChecksumMismatchEndsLog(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "good", "flipped")
+	l.Close()
+
+	path := filepath.Join(dir, logFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, c := openTestLog(t, dir)
+	defer l.Close()
+	if len(c.records) != 1 || c.records[0] != "good" {
+		t.Errorf("Expected only the intact record, got %v", c.records)
+	}
+}
+
+func TestCorruptRecordBeforeIntactOnesFailsOpen(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "first", "flipped", "third")
+	l.Close()
+
+	path := filepath.Join(dir, logFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[2*headerSize+len("first")] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &collector{}
+	if _, err := Open(dir, c.restore, c.apply); !errors.Is(err, ErrCorruptLog) {
+		t.Errorf("Expected ErrCorruptLog, got %v", err)
+	}
+	if kept, err := os.ReadFile(path); err != nil || len(kept) != len(data) {
+		t.Errorf("Expected the log to be kept, got %d of %d bytes", len(kept), len(data))
+	}
+}
+
+func TestSnapshotTruncatesLog(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "a", "b")
+	if err := l.Snapshot([]byte("state-ab")); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if l.Pending() != 0 {
+		t.Errorf("Expected no pending records after snapshot, got %d", l.Pending())
+	}
+	appendAll(t, l, "c")
+	l.Close()
+
+	l, c := openTestLog(t, dir)
+	defer l.Close()
+	if c.snapshot != "state-ab" {
+		t.Errorf("Expected snapshot state-ab, got %q", c.snapshot)
+	}
+	if len(c.records) != 1 || c.records[0] != "c" {
+		t.Errorf("Expected only records after the snapshot, got %v", c.records)
+	}
+}
+
+func TestCrashBetweenSnapshotAndTruncate(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "a", "b")
+
+	path := filepath.Join(dir, logFile)
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Snapshot([]byte("state-ab")); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	// Put the pre-snapshot log back as if truncation never happened
+	if err := os.WriteFile(path, before, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, c := openTestLog(t, dir)
+	if len(c.records) != 0 {
+		t.Errorf("Expected records covered by the snapshot to be skipped, got %v", c.records)
+	}
+	appendAll(t, l, "c")
+	l.Close()
+
+	l, c = openTestLog(t, dir)
+	defer l.Close()
+	if len(c.records) != 1 || c.records[0] != "c" {
+		t.Errorf("Expected new record after snapshot, got %v", c.records)
+	}
+}
+
+func TestLeftoverTempSnapshotIsIgnored(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "a")
+	l.Close()
+
+	// Simulate a crash while the snapshot was still being written
+	tmp := filepath.Join(dir, snapshotFile+tempSuffix)
+	if err := os.WriteFile(tmp, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, c := openTestLog(t, dir)
+	defer l.Close()
+	if c.snapshot != "" || len(c.records) != 1 {
+		t.Errorf("Expected only the log to be replayed, got snapshot %q records %v", c.snapshot, c.records)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("Expected temporary snapshot to be removed")
+	}
+}
+
+func TestCorruptSnapshotFailsOpen(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, snapshotFile), []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &collector{}
+	if _, err := Open(dir, c.restore, c.apply); err == nil {
+		t.Error("Expected error for corrupt snapshot")
+	}
+}
+
+func TestPing(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	appendAll(t, l, "a")
+	if err := l.Ping(); err != nil {
+		t.Fatalf("Expected an open log to be healthy, got %v", err)
+	}
+
+	os.Remove(filepath.Join(dir, logFile))
+	if err := l.Ping(); err == nil {
+		t.Error("Expected a removed log to be unhealthy")
+	}
+	l.Close()
+	if err := l.Ping(); err == nil {
+		t.Error("Expected a closed log to be unhealthy")
+	}
+	if err := l.Append([]byte("b")); err == nil {
+		t.Fatal("Expected Append to a closed log to fail")
+	}
+	if err := l.Ping(); err == nil {
+		t.Error("Expected a failed append to be reported")
+	}
+}
@@ -20,50 +20,146 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"order-service/internal/httpx"
+	"order-service/internal/payment"
+	"order-service/internal/userclient"
+	"order-service/internal/wal"
 )
 
 // Order represents an order in the system
 type Order struct {
-	ID       int     `json:"id"`
-	UserID   int     `json:"user_id"`
-	Product  string  `json:"product"`
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
-	Status   string  `json:"status"`
-	Created  string  `json:"created"`
+	ID       int         `json:"id"`
+	UserID   int         `json:"user_id"`
+	Currency string      `json:"currency"`
+	Items    []OrderItem `json:"items"`
+	Total    Money       `json:"total"`
+	Status   string      `json:"status"`
+	Created  string      `json:"created"`
+	
+	// PaymentStatus is one of the PaymentStatus values
+	PaymentStatus string `json:"payment_status"`
+	
+	// Payment is set once the payment has been authorized
+	Payment *PaymentInfo `json:"payment,omitempty"`
+	
+	// Shipping is set for orders that are delivered; its cost is part of Total
+	Shipping *ShippingInfo `json:"shipping,omitempty"`
+	
+	// Cancellation records who cancelled the order and why
+	Cancellation *Cancellation `json:"cancellation,omitempty"`
+	
+	// NeedsUserCheck marks orders accepted while user-service was unreachable
+	NeedsUserCheck bool `json:"needs_user_check,omitempty"`
+	
+	// LegacyProduct, LegacyQuantity and LegacyPrice hold orders persisted
+	// before line items; install converts them into a single item
+	LegacyProduct  string  `json:"product,omitempty"`
+	LegacyQuantity int     `json:"quantity,omitempty"`
+	LegacyPrice    float64 `json:"price,omitempty"`
 }
 
-// User represents user data from user-service
-type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+// Payment statuses, mirroring PaymentStatus in order.proto
+const (
+	PaymentStatusPending    = "pending"
+	PaymentStatusProcessing = "processing"
+	PaymentStatusCompleted  = "completed"
+	PaymentStatusFailed     = "failed"
+	PaymentStatusCancelled  = "cancelled"
+	PaymentStatusRefunded   = "refunded"
+)
+
+// paymentStatuses lists every valid payment status
+var paymentStatuses = map[string]bool{
+	PaymentStatusPending:    true,
+	PaymentStatusProcessing: true,
+	PaymentStatusCompleted:  true,
+	PaymentStatusFailed:     true,
+	PaymentStatusCancelled:  true,
+	PaymentStatusRefunded:   true,
 }
 
+// Outcomes of looking up the user attached to an order
+const (
+	UserLookupOK          = "ok"
+	UserLookupNotFound    = "not_found"
+	UserLookupUnavailable = "unavailable"
+	UserLookupError       = "error"
+)
+
 // OrderWithUser represents an order with user information
 type OrderWithUser struct {
 	Order
-	UserName  string `json:"user_name,omitempty"`
-	UserEmail string `json:"user_email,omitempty"`
+	UserName   string `json:"user_name,omitempty"`
+	UserEmail  string `json:"user_email,omitempty"`
+	UserLookup string `json:"user_lookup"`
+}
+
+// Policies applied to order creation when user-service cannot be reached
+const (
+	UserCheckReject = "reject"
+	UserCheckAccept = "accept"
+)
+
+// errUnknownUser is returned when an order references a user that does not exist
+var errUnknownUser = errors.New("user does not exist")
+
+// errUserSuspended is returned when an order references a suspended user
+var errUserSuspended = errors.New("user is suspended")
+
+// UserFetcher retrieves users from user-service
+type UserFetcher interface {
+	GetUser(ctx context.Context, id int) (*userclient.User, error)
 }
 
-// OrderStore provides in-memory storage for orders
+// ErrOrderNotFound is returned when an order does not exist
+var ErrOrderNotFound = errors.New("order not found")
+
+// OrderStore provides in-memory storage for orders, optionally backed by a
+// write-ahead log
 type OrderStore struct {
 	orders map[int]*Order
 	mutex  sync.RWMutex
 	nextID int
+	users  UserFetcher
+	
+	// payments takes order payments; paymentLocks serializes the calls made
+	// for each order
+	payments     payment.Provider
+	paymentLocks orderLocks
+	
+	// shippingRates prices the shipping methods orders may use
+	shippingRates ShippingRates
+	
+	// cancelHooks run after every cancellation; see OnCancel
+	cancelHooks []CancelHook
+	
+	// index speeds up filtered listings; see orderIndex
+	index *orderIndex
+	
+	// history holds the status changes of each order, oldest first
+	history map[int][]StatusChange
+	
+	// userCheckPolicy is UserCheckReject or UserCheckAccept
+	userCheckPolicy string
+	
+	// wal persists every mutation when the store is durable
+	wal           *wal.Log
+	snapshotEvery int
 }
 
 // Prometheus metrics
@@ -84,56 +180,125 @@ var (
 		[]string{"method", "endpoint"},
 	)
 	
-	orderCounter = prometheus.NewCounterVec(
+	ordersCreated = prometheus.NewCounter(
 		prometheus.CounterOpts{
-			Name: "orders_total",
+			Name: "orders_created_total",
 			Help: "Total number of orders created",
 		},
-		[]string{"status"},
+	)
+	
+	orderTransitionsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_status_transitions_total",
+			Help: "Total number of order status transitions",
+		},
+		[]string{"from", "to"},
 	)
 )
 
 func init() {
 	prometheus.MustRegister(httpRequests)
 	prometheus.MustRegister(httpDuration)
-	prometheus.MustRegister(orderCounter)
+	prometheus.MustRegister(ordersCreated)
+	prometheus.MustRegister(orderTransitionsCounter)
 }
 
-// NewOrderStore creates a new order store
+// NewOrderStore creates a new in-memory order store with sample data. It
+// exits when the sample data cannot be added.
 func NewOrderStore() *OrderStore {
+	store := newOrderStore()
+	if err := store.seed(); err != nil {
+		log.Fatal("Failed to seed order store:", err)
+	}
+	return store
+}
+
+// newOrderStore creates an empty order store
+func newOrderStore() *OrderStore {
 	store := &OrderStore{
 		orders: make(map[int]*Order),
 		nextID: 1,
+		users:  userclient.NewFromEnv(),
+		
+		payments: payment.NewFake(),
+		
+		shippingRates: defaultShippingRates,
+		
+		index: newOrderIndex(),
+		
+		history: make(map[int][]StatusChange),
+		
+		userCheckPolicy: userCheckPolicyFromEnv(),
 	}
-	
-	// Add some sample data
-	store.CreateOrder(1, "Laptop", 1, 999.99)
-	store.CreateOrder(2, "Mouse", 2, 25.00)
-	
+	store.OnCancel(store.releasePayment)
 	return store
 }
 
-// CreateOrder creates a new order
-func (s *OrderStore) CreateOrder(userID int, product string, quantity int, price float64) *Order {
+// seed adds some sample data
+func (s *OrderStore) seed() error {
+	if _, err := s.CreateOrder(1, singleItem("Laptop", 1, Money{Amount: 99999, Currency: defaultCurrency})); err != nil {
+		return err
+	}
+	_, err := s.CreateOrder(2, singleItem("Mouse", 2, Money{Amount: 2500, Currency: defaultCurrency}))
+	return err
+}
+
+// CreateOrder creates a new order from its line items, computing the line
+// and order totals. The order takes the currency of its items.
+func (s *OrderStore) CreateOrder(userID int, items []OrderItem) (*Order, error) {
+	return s.CreateOrderWithShipping(userID, items, nil)
+}
+
+// CreateOrderWithShipping creates a new order delivered as described by
+// shipping, adding the shipping cost from the rate table to the total. A nil
+// shipping creates an order without delivery.
+func (s *OrderStore) CreateOrderWithShipping(userID int, items []OrderItem, shipping *ShippingInfo) (*Order, error) {
+	return s.createOrder(userID, items, shipping, false)
+}
+
+// createOrder creates an order like CreateOrderWithShipping. needsUserCheck
+// flags orders whose user could not be verified in the same commit that
+// creates them, so that no order is ever persisted without its flag.
+func (s *OrderStore) createOrder(userID int, items []OrderItem, shipping *ShippingInfo, needsUserCheck bool) (*Order, error) {
+	items, total, err := priceItems(items)
+	if err != nil {
+		return nil, err
+	}
+	
+	now := time.Now()
+	if shipping != nil {
+		if shipping, err = s.shippingRates.priceShipping(*shipping, total, totalUnits(items), now); err != nil {
+			return nil, err
+		}
+		if total, err = total.Add(shipping.Cost); err != nil {
+			return nil, err
+		}
+	}
+	
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
 	order := &Order{
 		ID:       s.nextID,
 		UserID:   userID,
-		Product:  product,
-		Quantity: quantity,
-		Price:    price,
-		Status:   "pending",
-		Created:  time.Now().Format(time.RFC3339),
+		Currency: total.Currency,
+		Items:    items,
+		Total:    total,
+		Status:   StatusPending,
+		Created:  now.Format(time.RFC3339),
+		
+		PaymentStatus:  PaymentStatusPending,
+		Shipping:       shipping,
+		NeedsUserCheck: needsUserCheck,
 	}
 	
-	s.orders[order.ID] = order
-	s.nextID++
+	if err := s.commit(order); err != nil {
+		return nil, err
+	}
 	
-	orderCounter.WithLabelValues(order.Status).Inc()
+	ordersCreated.Inc()
 	
-	return order
+	return order, nil
 }
 
 // GetOrder retrieves an order by ID
@@ -164,85 +329,120 @@ func (s *OrderStore) GetOrdersByUser(userID int) []*Order {
 	defer s.mutex.RUnlock()
 	
 	var userOrders []*Order
-	for _, order := range s.orders {
-		if order.UserID == userID {
-			userOrders = append(userOrders, order)
-		}
+	for id := range s.index.byUser[userID] {
+		userOrders = append(userOrders, s.orders[id])
 	}
 	
 	return userOrders
 }
 
-// UpdateOrderStatus updates the status of an order
-func (s *OrderStore) UpdateOrderStatus(id int, status string) bool {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	
-	order, exists := s.orders[id]
-	if !exists {
-		return false
+// UpdateOrderStatus moves an order to status on behalf of the system
+func (s *OrderStore) UpdateOrderStatus(id int, status string) error {
+	_, err := s.TransitionOrder(id, status, systemActor, "")
+	return err
+}
+
+// userCheckPolicyFromEnv reads USER_CHECK_POLICY, defaulting to UserCheckReject
+func userCheckPolicyFromEnv() string {
+	policy := getEnv("USER_CHECK_POLICY", UserCheckReject)
+	if policy != UserCheckAccept && policy != UserCheckReject {
+		log.Printf("Unknown USER_CHECK_POLICY %q, using %q", policy, UserCheckReject)
+		return UserCheckReject
 	}
-	
-	order.Status = status
-	orderCounter.WithLabelValues(status).Inc()
-	
-	return true
+	return policy
 }
 
-// fetchUserFromService fetches user data from user-service
-func fetchUserFromService(userID int) (*User, error) {
-	// In a real implementation, this would call the user-service
-	// For demo purposes, we'll simulate the response
-	url := fmt.Sprintf("http://user-service:8080/users/%d", userID)
+// verifyOrderUser checks with user-service that userID exists. It returns
+// needsCheck when user-service was unreachable and the policy accepts the order.
+func (s *OrderStore) verifyOrderUser(ctx context.Context, userID int) (needsCheck bool, err error) {
+	user, err := s.users.GetUser(ctx, userID)
+	switch {
+	case err == nil && user.Status == userclient.StatusSuspended:
+		return false, errUserSuspended
+	case err == nil:
+		return false, nil
+	case errors.Is(err, userclient.ErrNotFound):
+		return false, errUnknownUser
+	case s.userCheckPolicy == UserCheckAccept:
+		log.Printf("Accepting order for unverified user %d: %v", userID, err)
+		return true, nil
+	default:
+		return false, fmt.Errorf("verify user %d: %w", userID, err)
+	}
+}
+
+// userLookupResult classifies the error returned by a user lookup
+func userLookupResult(err error) string {
+	switch {
+	case err == nil:
+		return UserLookupOK
+	case errors.Is(err, userclient.ErrNotFound):
+		return UserLookupNotFound
+	case errors.Is(err, userclient.ErrUnavailable):
+		return UserLookupUnavailable
+	default:
+		return UserLookupError
+	}
+}
+
+// HTTP Handlers
+func (s *OrderStore) handleGetOrders(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/orders"))
+	defer timer.ObserveDuration()
 	
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+	query, err := ParseOrderQuery(r.URL.Query())
 	if err != nil {
-		// Fallback for local development
-		return &User{
-			ID:    userID,
-			Name:  fmt.Sprintf("User %d", userID),
-			Email: fmt.Sprintf("user%d@example.com", userID),
-		}, nil
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/orders", "400").Inc()
+		return
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("user service returned status %d", resp.StatusCode)
+	page, err := s.ListOrders(query)
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/orders", "400").Inc()
+		return
 	}
 	
-	var user User
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, err
+	// The body stays a plain array; paging details travel in headers
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.TotalCount))
+	if page.NextPageToken != "" {
+		w.Header().Set("X-Next-Page-Token", page.NextPageToken)
 	}
+	json.NewEncoder(w).Encode(page.Orders)
 	
-	return &user, nil
+	httpRequests.WithLabelValues(r.Method, "/orders", "200").Inc()
 }
 
-// HTTP Handlers
-func (s *OrderStore) handleGetOrders(w http.ResponseWriter, r *http.Request) {
-	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/orders"))
+func (s *OrderStore) handleGetOrderMetrics(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/orders/metrics"))
 	defer timer.ObserveDuration()
 	
-	userIDStr := r.URL.Query().Get("user_id")
-	var orders []*Order
+	query, err := ParseOrderStatsQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/orders/metrics", "400").Inc()
+		return
+	}
 	
-	if userIDStr != "" {
-		userID, err := strconv.Atoi(userIDStr)
-		if err != nil {
-			httpx.WriteError(w, http.StatusBadRequest, "Invalid user ID")
-			httpRequests.WithLabelValues(r.Method, "/orders", "400").Inc()
-			return
-		}
-		orders = s.GetOrdersByUser(userID)
-	} else {
-		orders = s.GetAllOrders()
+	report, err := s.OrderStats(query)
+	if errors.Is(err, ErrInvalidQuery) {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/orders/metrics", "400").Inc()
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to compute order metrics: %v", err)
+		httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to compute order metrics")
+		httpRequests.WithLabelValues(r.Method, "/orders/metrics", "500").Inc()
+		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	json.NewEncoder(w).Encode(report)
 	
-	httpRequests.WithLabelValues(r.Method, "/orders", "200").Inc()
+	httpRequests.WithLabelValues(r.Method, "/orders/metrics", "200").Inc()
 }
 
 func (s *OrderStore) handleGetOrder(w http.ResponseWriter, r *http.Request) {
@@ -252,23 +452,27 @@ func (s *OrderStore) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		httpx.WriteError(w, http.StatusBadRequest, "Invalid order ID")
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid order ID")
 		httpRequests.WithLabelValues(r.Method, "/orders/{id}", "400").Inc()
 		return
 	}
 	
 	order, exists := s.GetOrder(id)
 	if !exists {
-		httpx.WriteError(w, http.StatusNotFound, "Order not found")
+		httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "Order not found")
 		httpRequests.WithLabelValues(r.Method, "/orders/{id}", "404").Inc()
 		return
 	}
 	
 	// Try to fetch user information
 	orderWithUser := OrderWithUser{Order: *order}
-	if user, err := fetchUserFromService(order.UserID); err == nil {
+	user, err := s.users.GetUser(r.Context(), order.UserID)
+	orderWithUser.UserLookup = userLookupResult(err)
+	if err == nil {
 		orderWithUser.UserName = user.Name
 		orderWithUser.UserEmail = user.Email
+	} else {
+		log.Printf("Failed to load user %d for order %d: %v", order.UserID, order.ID, err)
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -282,25 +486,95 @@ func (s *OrderStore) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
 	defer timer.ObserveDuration()
 	
 	var req struct {
-		UserID   int     `json:"user_id"`
-		Product  string  `json:"product"`
-		Quantity int     `json:"quantity"`
-		Price    float64 `json:"price"`
+		UserID   int                `json:"user_id"`
+		Currency string             `json:"currency"`
+		Items    []orderItemRequest `json:"items"`
+		Shipping *ShippingInfo      `json:"shipping"`
+		
+		// Product, Quantity and Price create a single-item order for
+		// clients written before line items
+		Product  string     `json:"product"`
+		Quantity int        `json:"quantity"`
+		Price    PriceInput `json:"price"`
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpx.WriteError(w, http.StatusBadRequest, "Invalid JSON")
+		if errors.Is(err, ErrInvalidAmount) || errors.Is(err, ErrUnsupportedCurrency) {
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, err.Error())
+		} else {
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid JSON")
+		}
 		httpRequests.WithLabelValues(r.Method, "/orders", "400").Inc()
 		return
 	}
 	
-	if req.UserID <= 0 || req.Product == "" || req.Quantity <= 0 || req.Price <= 0 {
-		httpx.WriteError(w, http.StatusBadRequest, "All fields are required and must be valid")
+	if len(req.Items) == 0 && (req.Product != "" || req.Quantity != 0 || !req.Price.IsZero()) {
+		req.Items = []orderItemRequest{{Product: req.Product, Quantity: req.Quantity, UnitPrice: req.Price}}
+	}
+	if req.Currency == "" {
+		req.Currency = defaultCurrency
+	}
+	
+	if req.UserID <= 0 {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "All fields are required and must be valid",
+			httpx.FieldError{Field: "user_id", Message: "must be a positive user ID"})
 		httpRequests.WithLabelValues(r.Method, "/orders", "400").Inc()
 		return
 	}
 	
-	order := s.CreateOrder(req.UserID, req.Product, req.Quantity, req.Price)
+	currency, err := normalizeCurrency(req.Currency)
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, err.Error(),
+			httpx.FieldError{Field: "currency", Message: err.Error()})
+		httpRequests.WithLabelValues(r.Method, "/orders", "400").Inc()
+		return
+	}
+	
+	items, err := resolveItems(currency, req.Items)
+	if err == nil {
+		var total Money
+		items, total, err = priceItems(items)
+		if err == nil && req.Shipping != nil {
+			_, err = s.shippingRates.priceShipping(*req.Shipping, total, totalUnits(items), time.Now())
+		}
+	}
+	if err != nil {
+		field := "items"
+		if errors.Is(err, ErrInvalidShipping) {
+			field = "shipping"
+		}
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, err.Error(),
+			httpx.FieldError{Field: field, Message: err.Error()})
+		httpRequests.WithLabelValues(r.Method, "/orders", "400").Inc()
+		return
+	}
+	
+	needsCheck, err := s.verifyOrderUser(r.Context(), req.UserID)
+	if errors.Is(err, errUnknownUser) {
+		httpx.WriteError(w, r, http.StatusUnprocessableEntity, httpx.CodeValidationFailed, "User does not exist",
+			httpx.FieldError{Field: "user_id", Message: "no such user"})
+		httpRequests.WithLabelValues(r.Method, "/orders", "422").Inc()
+		return
+	}
+	if errors.Is(err, errUserSuspended) {
+		httpx.WriteError(w, r, http.StatusForbidden, httpx.CodeForbidden, "User is suspended")
+		httpRequests.WithLabelValues(r.Method, "/orders", "403").Inc()
+		return
+	}
+	if err != nil {
+		log.Printf("Rejecting order: %v", err)
+		httpx.WriteError(w, r, http.StatusServiceUnavailable, httpx.CodeUpstreamUnavailable, "User service unavailable")
+		httpRequests.WithLabelValues(r.Method, "/orders", "503").Inc()
+		return
+	}
+	
+	order, err := s.createOrder(req.UserID, items, req.Shipping, needsCheck)
+	if err != nil {
+		log.Printf("Failed to create order: %v", err)
+		httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to create order")
+		httpRequests.WithLabelValues(r.Method, "/orders", "500").Inc()
+		return
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -316,34 +590,57 @@ func (s *OrderStore) handleUpdateOrderStatus(w http.ResponseWriter, r *http.Requ
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		httpx.WriteError(w, http.StatusBadRequest, "Invalid order ID")
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid order ID")
 		httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "400").Inc()
 		return
 	}
 	
 	var req struct {
 		Status string `json:"status"`
+		Reason string `json:"reason"`
+		
+		// TrackingNumber is recorded when the order moves to shipped
+		TrackingNumber string `json:"tracking_number"`
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpx.WriteError(w, http.StatusBadRequest, "Invalid JSON")
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid JSON")
 		httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "400").Inc()
 		return
 	}
 	
-	validStatuses := map[string]bool{
-		"pending": true, "processing": true, "shipped": true, "delivered": true, "cancelled": true,
-	}
-	
-	if !validStatuses[req.Status] {
-		httpx.WriteError(w, http.StatusBadRequest, "Invalid status")
+	if _, known := orderTransitions[req.Status]; !known {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "Invalid status",
+			httpx.FieldError{Field: "status", Message: "unknown order status"})
 		httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "400").Inc()
 		return
 	}
 	
-	if !s.UpdateOrderStatus(id, req.Status) {
-		httpx.WriteError(w, http.StatusNotFound, "Order not found")
-		httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "404").Inc()
+	if req.Status == StatusShipped && req.TrackingNumber != "" {
+		_, err = s.ShipOrder(id, req.TrackingNumber, requestActor(r), req.Reason)
+	} else {
+		_, err = s.TransitionOrder(id, req.Status, requestActor(r), req.Reason)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidShipping) {
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, err.Error(),
+				httpx.FieldError{Field: "tracking_number", Message: err.Error()})
+			httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "400").Inc()
+			return
+		}
+		if errors.Is(err, ErrOrderNotFound) {
+			httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "Order not found")
+			httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "404").Inc()
+			return
+		}
+		if errors.Is(err, ErrInvalidTransition) {
+			httpx.WriteError(w, r, http.StatusConflict, httpx.CodeInvalidTransition, "Cannot change order status: "+err.Error())
+			httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "409").Inc()
+			return
+		}
+		log.Printf("Failed to update order %d: %v", id, err)
+		httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to update order")
+		httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "500").Inc()
 		return
 	}
 	
@@ -354,28 +651,260 @@ func (s *OrderStore) handleUpdateOrderStatus(w http.ResponseWriter, r *http.Requ
 	httpRequests.WithLabelValues(r.Method, "/orders/{id}/status", "200").Inc()
 }
 
+func (s *OrderStore) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/orders/{id}/cancel"))
+	defer timer.ObserveDuration()
+	
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid order ID")
+		httpRequests.WithLabelValues(r.Method, "/orders/{id}/cancel", "400").Inc()
+		return
+	}
+	
+	var req struct {
+		// Reason is one of the cancellation reason codes
+		Reason string `json:"reason"`
+		Note   string `json:"note"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid JSON")
+		httpRequests.WithLabelValues(r.Method, "/orders/{id}/cancel", "400").Inc()
+		return
+	}
+	
+	order, err := s.CancelOrder(r.Context(), id, req.Reason, req.Note, requestActor(r))
+	if err != nil {
+		if errors.Is(err, ErrInvalidCancelReason) || errors.Is(err, ErrCancelNoteTooLong) {
+			field := "reason"
+			if errors.Is(err, ErrCancelNoteTooLong) {
+				field = "note"
+			}
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, err.Error(),
+				httpx.FieldError{Field: field, Message: err.Error()})
+			httpRequests.WithLabelValues(r.Method, "/orders/{id}/cancel", "400").Inc()
+			return
+		}
+		if errors.Is(err, ErrOrderNotFound) {
+			httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "Order not found")
+			httpRequests.WithLabelValues(r.Method, "/orders/{id}/cancel", "404").Inc()
+			return
+		}
+		if errors.Is(err, ErrInvalidTransition) {
+			httpx.WriteError(w, r, http.StatusConflict, httpx.CodeInvalidTransition, "Cannot cancel order: "+err.Error())
+			httpRequests.WithLabelValues(r.Method, "/orders/{id}/cancel", "409").Inc()
+			return
+		}
+		log.Printf("Failed to cancel order %d: %v", id, err)
+		httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to cancel order")
+		httpRequests.WithLabelValues(r.Method, "/orders/{id}/cancel", "500").Inc()
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+	
+	httpRequests.WithLabelValues(r.Method, "/orders/{id}/cancel", "200").Inc()
+}
+
+func (s *OrderStore) handleGetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/orders/{id}/history"))
+	defer timer.ObserveDuration()
+	
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid order ID")
+		httpRequests.WithLabelValues(r.Method, "/orders/{id}/history", "400").Inc()
+		return
+	}
+	
+	history, err := s.GetOrderHistory(id)
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "Order not found")
+		httpRequests.WithLabelValues(r.Method, "/orders/{id}/history", "404").Inc()
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+	
+	httpRequests.WithLabelValues(r.Method, "/orders/{id}/history", "200").Inc()
+}
+
+// errInvalidJSON is returned by handler actions whose request body cannot be decoded
+var errInvalidJSON = errors.New("invalid JSON")
+
+// handlePaymentAction serves a payment endpoint that runs action on the order
+// named in the path and responds with the updated order
+func (s *OrderStore) handlePaymentAction(endpoint string, action func(r *http.Request, id int) (*Order, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, endpoint))
+		defer timer.ObserveDuration()
+		
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid order ID")
+			httpRequests.WithLabelValues(r.Method, endpoint, "400").Inc()
+			return
+		}
+		
+		order, err := action(r, id)
+		switch {
+		case errors.Is(err, ErrOrderNotFound):
+			httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "Order not found")
+			httpRequests.WithLabelValues(r.Method, endpoint, "404").Inc()
+			return
+		case errors.Is(err, errInvalidJSON):
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid JSON")
+			httpRequests.WithLabelValues(r.Method, endpoint, "400").Inc()
+			return
+		case errors.Is(err, ErrInvalidPaymentMethod):
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, err.Error(),
+				httpx.FieldError{Field: "method", Message: err.Error()})
+			httpRequests.WithLabelValues(r.Method, endpoint, "400").Inc()
+			return
+		case errors.Is(err, payment.ErrDeclined):
+			httpx.WriteError(w, r, http.StatusPaymentRequired, httpx.CodePaymentDeclined, "Payment declined")
+			httpRequests.WithLabelValues(r.Method, endpoint, "402").Inc()
+			return
+		case errors.Is(err, ErrPaymentState), errors.Is(err, payment.ErrInvalidState):
+			httpx.WriteError(w, r, http.StatusConflict, httpx.CodeInvalidTransition, "Cannot process payment: "+err.Error())
+			httpRequests.WithLabelValues(r.Method, endpoint, "409").Inc()
+			return
+		case err != nil:
+			log.Printf("Payment for order %d failed: %v", id, err)
+			httpx.WriteError(w, r, http.StatusBadGateway, httpx.CodeUpstreamFailed, "Payment provider error")
+			httpRequests.WithLabelValues(r.Method, endpoint, "502").Inc()
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(order)
+		
+		httpRequests.WithLabelValues(r.Method, endpoint, "200").Inc()
+	}
+}
+
+// authorizePayment decodes the payment method of an authorize request
+func (s *OrderStore) authorizePayment(r *http.Request, id int) (*Order, error) {
+	var req struct {
+		Method string `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errInvalidJSON
+	}
+	return s.AuthorizePayment(r.Context(), id, req.Method)
+}
+
+// capturePayment captures on behalf of the caller
+func (s *OrderStore) capturePayment(r *http.Request, id int) (*Order, error) {
+	return s.CapturePayment(r.Context(), id, requestActor(r))
+}
+
+// refundPayment refunds the order's payment
+func (s *OrderStore) refundPayment(r *http.Request, id int) (*Order, error) {
+	return s.RefundPayment(r.Context(), id)
+}
+
+// defaultCORSConfig allows any origin without credentials, as before the
+// policy became configurable
+var defaultCORSConfig = httpx.CORSConfig{
+	AllowedOrigins: []string{"*"},
+	AllowedHeaders: []string{"Content-Type", "Authorization", "Idempotency-Key", httpx.RequestIDHeader, "X-Actor"},
+	ExposedHeaders: []string{"X-Total-Count", "X-Next-Page-Token", httpx.IdempotentReplayedHeader, httpx.RequestIDHeader},
+	MaxAge:         10 * time.Minute,
+}
+
+// defaultIdempotencyTTL is how long responses to requests with an
+// Idempotency-Key are kept when IDEMPOTENCY_TTL is not set
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyTTLFromEnv reads IDEMPOTENCY_TTL as a Go duration such as "1h"
+func idempotencyTTLFromEnv() time.Duration {
+	value := os.Getenv("IDEMPOTENCY_TTL")
+	if value == "" {
+		return defaultIdempotencyTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Invalid IDEMPOTENCY_TTL %q, using %s", value, defaultIdempotencyTTL)
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func main() {
-	store := NewOrderStore()
+	store, err := orderStoreFromEnv()
+	if err != nil {
+		log.Fatal("Failed to open order store:", err)
+	}
+	prometheus.MustRegister(NewOrderStatusCollector(store))
 	
-	r := mux.NewRouter()
-	r.Use(httpx.CORSMiddleware)
-	r.HandleFunc("/health", httpx.NewHealthHandler("order-service")).Methods("GET")
-	r.HandleFunc("/author", httpx.AuthorHandler).Methods("GET")
+	corsConfig, err := httpx.CORSConfigFromEnv(defaultCORSConfig)
+	if err != nil {
+		log.Fatal("Invalid CORS settings:", err)
+	}
+	idempotency := httpx.NewIdempotency(idempotencyTTLFromEnv())
+	healthConfig, err := httpx.HealthConfigFromEnv()
+	if err != nil {
+		log.Fatal("Invalid health check settings:", err)
+	}
+	usersRequired, err := strconv.ParseBool(getEnv("USER_SERVICE_REQUIRED", "false"))
+	if err != nil {
+		log.Fatal("Invalid USER_SERVICE_REQUIRED:", err)
+	}
+	health := newServiceHealth(store, healthConfig, usersRequired)
+	
+	r := httpx.NewRouter(health)
 	r.HandleFunc("/orders", store.handleGetOrders).Methods("GET")
+	r.HandleFunc("/orders/metrics", store.handleGetOrderMetrics).Methods("GET")
 	r.HandleFunc("/orders/{id:[0-9]+}", store.handleGetOrder).Methods("GET")
-	r.HandleFunc("/orders", store.handleCreateOrder).Methods("POST")
+	r.Handle("/orders", idempotency.Middleware(http.HandlerFunc(store.handleCreateOrder))).Methods("POST")
 	r.HandleFunc("/orders/{id:[0-9]+}/status", store.handleUpdateOrderStatus).Methods("PUT")
+	r.HandleFunc("/orders/{id:[0-9]+}/history", store.handleGetOrderHistory).Methods("GET")
+	r.HandleFunc("/orders/{id:[0-9]+}/cancel", store.handleCancelOrder).Methods("POST")
+	r.HandleFunc("/orders/{id:[0-9]+}/payment/authorize", store.handlePaymentAction("/orders/{id}/payment/authorize", store.authorizePayment)).Methods("POST")
+	r.HandleFunc("/orders/{id:[0-9]+}/payment/capture", store.handlePaymentAction("/orders/{id}/payment/capture", store.capturePayment)).Methods("POST")
+	r.HandleFunc("/orders/{id:[0-9]+}/payment/refund", store.handlePaymentAction("/orders/{id}/payment/refund", store.refundPayment)).Methods("POST")
 	
 	// Metrics endpoint
 	r.Handle("/metrics", promhttp.Handler())
 	
+	grpcPort := ":" + getEnv("GRPC_PORT", "50052")
+	lis, err := net.Listen("tcp", grpcPort)
+	if err != nil {
+		log.Fatal("gRPC listener failed:", err)
+	}
+	grpcServer := NewGRPCServer(store, health)
+	var grpcWorker httpx.Worker
+	grpcWorker.Go(func() error {
+		log.Printf("gRPC server listening on port %s", grpcPort)
+		err := grpcServer.Serve(lis)
+		log.Printf("gRPC server stopped: %v", err)
+		return err
+	})
+	// A stopped gRPC server fails liveness so that the pod is restarted
+	health.Register("grpc", httpx.Liveness, &grpcWorker)
+	
 	port := ":8081"
 	log.Printf("Order Service starting on port %s", port)
-	log.Printf("Health check: http://localhost%s/health", port)
+	log.Printf("Health checks: http://localhost%s/livez and /readyz", port)
 	log.Printf("API endpoint: http://localhost%s/orders", port)
 	log.Printf("Metrics: http://localhost%s/metrics", port)
 	
-	if err := http.ListenAndServe(port, r); err != nil {
+	if err := httpx.ListenAndServe(port, r, corsConfig); err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
 } 
\ No newline at end of file
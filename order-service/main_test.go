@@ -2,13 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
 	"github.com/gorilla/mux"
-	"order-service/internal/httpx"
+	"order-service/internal/payment"
+	"order-service/internal/userclient"
 )
 
 func TestNewOrderStore(t *testing.T) {
@@ -30,7 +37,10 @@ func TestNewOrderStore(t *testing.T) {
 func TestCreateOrder(t *testing.T) {
 	store := NewOrderStore()
 	
-	order := store.CreateOrder(1, "Test Product", 2, 99.99)
+	order, err := store.CreateOrder(1, singleItem("Test Product", 2, usd(9999)))
+	if err != nil {
+		t.Fatalf("CreateOrder() failed: %v", err)
+	}
 	
 	if order == nil {
 		t.Fatal("CreateOrder() returned nil")
@@ -40,16 +50,16 @@ func TestCreateOrder(t *testing.T) {
 		t.Errorf("Expected UserID 1, got %d", order.UserID)
 	}
 	
-	if order.Product != "Test Product" {
-		t.Errorf("Expected product 'Test Product', got %s", order.Product)
+	if len(order.Items) != 1 || order.Items[0].Product != "Test Product" {
+		t.Fatalf("Expected one 'Test Product' item, got %+v", order.Items)
 	}
 	
-	if order.Quantity != 2 {
-		t.Errorf("Expected quantity 2, got %d", order.Quantity)
+	if order.Items[0].Quantity != 2 {
+		t.Errorf("Expected quantity 2, got %d", order.Items[0].Quantity)
 	}
 	
-	if order.Price != 99.99 {
-		t.Errorf("Expected price 99.99, got %f", order.Price)
+	if order.Items[0].UnitPrice.Decimal() != "99.99" || order.Total.Decimal() != "199.98" {
+		t.Errorf("Expected price 99.99 and total 199.98, got %s and %s", order.Items[0].UnitPrice, order.Total)
 	}
 	
 	if order.Status != "pending" {
@@ -84,7 +94,7 @@ func TestGetOrdersByUser(t *testing.T) {
 	store := NewOrderStore()
 	
 	// Add an order for user 1
-	store.CreateOrder(1, "User 1 Product", 1, 50.0)
+	store.CreateOrder(1, singleItem("User 1 Product", 1, usd(5000)))
 	
 	orders := store.GetOrdersByUser(1)
 	if len(orders) == 0 {
@@ -102,9 +112,9 @@ func TestUpdateOrderStatus(t *testing.T) {
 	store := NewOrderStore()
 	
 	// Test updating existing order
-	success := store.UpdateOrderStatus(1, "processing")
-	if !success {
-		t.Error("Expected UpdateOrderStatus to succeed for existing order")
+	payOrder(t, store, 1)
+	if err := store.UpdateOrderStatus(1, "processing"); err != nil {
+		t.Errorf("Expected UpdateOrderStatus to succeed for existing order, got %v", err)
 	}
 	
 	order, _ := store.GetOrder(1)
@@ -113,9 +123,8 @@ func TestUpdateOrderStatus(t *testing.T) {
 	}
 	
 	// Test updating non-existing order
-	success = store.UpdateOrderStatus(999, "shipped")
-	if success {
-		t.Error("Expected UpdateOrderStatus to fail for non-existing order")
+	if err := store.UpdateOrderStatus(999, "shipped"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound for non-existing order, got %v", err)
 	}
 }
 
@@ -125,8 +134,11 @@ func TestHealthHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 	
+	store := NewOrderStore()
+	store.users = stubUserFetcher{}
+	
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(httpx.NewHealthHandler("order-service"))
+	handler := http.HandlerFunc(newServiceHealth(store, httpx.HealthConfig{}, false).ReadinessHandler)
 	
 	handler.ServeHTTP(rr, req)
 	
@@ -134,17 +146,21 @@ func TestHealthHandler(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, status)
 	}
 	
-	var response map[string]string
+	var response httpx.HealthReport
 	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
 		t.Fatal("Failed to parse JSON response")
 	}
 	
-	if response["status"] != "healthy" {
-		t.Errorf("Expected status 'healthy', got %s", response["status"])
+	if response.Status != "healthy" {
+		t.Errorf("Expected status 'healthy', got %s", response.Status)
 	}
 	
-	if response["service"] != "order-service" {
-		t.Errorf("Expected service 'order-service', got %s", response["service"])
+	if response.Service != "order-service" {
+		t.Errorf("Expected service 'order-service', got %s", response.Service)
+	}
+	
+	if len(response.Checks) != 1 || response.Checks[0].Name != "store" {
+		t.Errorf("Expected the store check, got %+v", response.Checks)
 	}
 }
 
@@ -205,8 +221,51 @@ func TestHandleGetOrdersWithUserFilter(t *testing.T) {
 	}
 }
 
+func TestHandleGetOrdersPagination(t *testing.T) {
+	store := NewOrderStore()
+	
+	rr := httptest.NewRecorder()
+	store.handleGetOrders(rr, httptest.NewRequest("GET", "/orders?page_size=1&sort_by=amount&sort_order=desc", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "2" {
+		t.Errorf("Expected X-Total-Count 2, got %q", total)
+	}
+	var orders []Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &orders); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if len(orders) != 1 || orders[0].Items[0].Product != "Laptop" {
+		t.Errorf("Expected the laptop first, got %+v", orders)
+	}
+	token := rr.Header().Get("X-Next-Page-Token")
+	if token == "" {
+		t.Fatal("Expected a next page token")
+	}
+	
+	rr = httptest.NewRecorder()
+	store.handleGetOrders(rr, httptest.NewRequest("GET", "/orders?page_size=1&sort_by=amount&sort_order=desc&page_token="+token, nil))
+	orders = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &orders); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if len(orders) != 1 || orders[0].Items[0].Product != "Mouse" || rr.Header().Get("X-Next-Page-Token") != "" {
+		t.Errorf("Expected the mouse on the last page, got %+v", orders)
+	}
+	
+	for _, url := range []string{"/orders?user_id=abc", "/orders?status=lost", "/orders?page_token=bogus", "/orders?min_amount=x"} {
+		rr := httptest.NewRecorder()
+		store.handleGetOrders(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", url, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
 func TestHandleCreateOrder(t *testing.T) {
 	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
 	
 	orderData := map[string]interface{}{
 		"user_id":  1,
@@ -244,16 +303,92 @@ func TestHandleCreateOrder(t *testing.T) {
 		t.Errorf("Expected UserID 1, got %d", order.UserID)
 	}
 	
-	if order.Product != "New Product" {
-		t.Errorf("Expected product 'New Product', got %s", order.Product)
+	if len(order.Items) != 1 || order.Items[0].Product != "New Product" {
+		t.Fatalf("Expected one 'New Product' item, got %+v", order.Items)
 	}
 	
-	if order.Quantity != 3 {
-		t.Errorf("Expected quantity 3, got %d", order.Quantity)
+	if order.Items[0].Quantity != 3 {
+		t.Errorf("Expected quantity 3, got %d", order.Items[0].Quantity)
 	}
 	
-	if order.Price != 149.99 {
-		t.Errorf("Expected price 149.99, got %f", order.Price)
+	if order.Items[0].UnitPrice.Decimal() != "149.99" || order.Total.Decimal() != "449.97" {
+		t.Errorf("Expected price 149.99 and total 449.97, got %s and %s", order.Items[0].UnitPrice, order.Total)
+	}
+}
+
+func TestHandleCreateOrderWithItems(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	
+	body := `{"user_id":1,"items":[
+		{"product":"Pen","quantity":3,"unit_price":0.1,"total_price":99},
+		{"product_id":"sku-2","product":"Ink","quantity":1,"unit_price":"0.20","attributes":{"color":"blue"}}
+	]}`
+	rr := postOrder(t, store, body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	
+	var order Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal(err)
+	}
+	if len(order.Items) != 2 || order.Items[1].ID != 2 || order.Items[1].Attributes["color"] != "blue" {
+		t.Fatalf("Expected two numbered items, got %+v", order.Items)
+	}
+	// The client total is ignored and 3 x 0.10 + 0.20 is exact
+	if order.Items[0].TotalPrice.Decimal() != "0.30" || order.Total.Decimal() != "0.50" {
+		t.Errorf("Expected totals 0.30 and 0.50, got %s and %s", order.Items[0].TotalPrice, order.Total)
+	}
+	if !strings.Contains(rr.Body.String(), `"total":{"amount":"0.50","currency":"USD"}`) {
+		t.Errorf("Expected total encoded as 0.50, got %s", rr.Body.String())
+	}
+}
+
+func TestHandleCreateOrderCurrency(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	
+	rr := postOrder(t, store, `{"user_id":1,"currency":"jpy","items":[{"product":"Tea","quantity":2,"unit_price":{"amount":"1500","currency":"JPY"}}]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var order Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Currency != "JPY" || order.Total != (Money{Amount: 3000, Currency: "JPY"}) {
+		t.Errorf("Expected a 3000 JPY order, got %s in %s", order.Total, order.Currency)
+	}
+	
+	bodies := []string{
+		`{"user_id":1,"currency":"XYZ","product":"Tea","quantity":1,"price":1}`,
+		`{"user_id":1,"currency":"EUR","items":[{"product":"Tea","quantity":1,"unit_price":{"amount":"1","currency":"USD"}}]}`,
+		`{"user_id":1,"items":[{"product":"Tea","quantity":1,"unit_price":{"amount":"1","currency":"XYZ"}}]}`,
+		`{"user_id":1,"currency":"JPY","product":"Tea","quantity":1,"price":1.5}`,
+	}
+	for _, body := range bodies {
+		if rr := postOrder(t, store, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", body, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHandleCreateOrderInvalidItems(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	
+	bodies := []string{
+		`{"user_id":1,"items":[]}`,
+		`{"user_id":1,"items":[{"product":"Pen","quantity":0,"unit_price":1}]}`,
+		`{"user_id":1,"items":[{"product":"","quantity":1,"unit_price":1}]}`,
+		`{"user_id":1,"items":[{"product":"Pen","quantity":1,"unit_price":0.001}]}`,
+		`{"user_id":1,"product":"Pen","quantity":1,"price":-2}`,
+	}
+	for _, body := range bodies {
+		if rr := postOrder(t, store, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", body, http.StatusBadRequest, rr.Code)
+		}
 	}
 }
 
@@ -310,9 +445,9 @@ func TestHandleUpdateOrderStatus(t *testing.T) {
 	store := NewOrderStore()
 	
 	// Since we can't easily mock mux.Vars in unit test, we'll test the core logic
-	success := store.UpdateOrderStatus(1, "processing")
-	if !success {
-		t.Error("Expected UpdateOrderStatus to succeed")
+	payOrder(t, store, 1)
+	if err := store.UpdateOrderStatus(1, "processing"); err != nil {
+		t.Errorf("Expected UpdateOrderStatus to succeed, got %v", err)
 	}
 	
 	order, _ := store.GetOrder(1)
@@ -324,7 +459,7 @@ func TestHandleUpdateOrderStatus(t *testing.T) {
 func TestHandleUpdateOrderStatusInvalidStatus(t *testing.T) {
 	// Test with invalid status - we'll test the validation logic directly
 	validStatuses := map[string]bool{
-		"pending": true, "processing": true, "shipped": true, "delivered": true, "cancelled": true,
+		"pending": true, "confirmed": true, "processing": true, "shipped": true, "delivered": true, "cancelled": true,
 	}
 	
 	invalidStatus := "invalid_status"
@@ -338,33 +473,152 @@ func TestHandleUpdateOrderStatusInvalidStatus(t *testing.T) {
 	}
 } 
 
-func TestFetchUserFromService(t *testing.T) {
-	// Test the fallback behavior when user service is not available
-	user, err := fetchUserFromService(1)
-	
-	// Should return fallback data without error
+// stubUserFetcher returns a fixed user or error for every lookup
+type stubUserFetcher struct {
+	user *userclient.User
+	err  error
+}
+
+func (f stubUserFetcher) GetUser(ctx context.Context, id int) (*userclient.User, error) {
+	return f.user, f.err
+}
+
+func getOrderWithUser(t *testing.T, store *OrderStore, id string) OrderWithUser {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/orders/"+id, nil)
 	if err != nil {
-		t.Errorf("Expected no error for fallback, got %v", err)
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	store.handleGetOrder(rr, mux.SetURLVars(req, map[string]string{"id": id}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var order OrderWithUser
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	return order
+}
+
+func TestHandleGetOrder_WithUser(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1, Name: "John Doe", Email: "john@example.com"}}
+
+	order := getOrderWithUser(t, store, "1")
+	if order.UserLookup != UserLookupOK {
+		t.Errorf("Expected user_lookup %q, got %q", UserLookupOK, order.UserLookup)
+	}
+	if order.UserName != "John Doe" || order.UserEmail != "john@example.com" {
+		t.Errorf("Unexpected user data: %s <%s>", order.UserName, order.UserEmail)
+	}
+}
+
+func TestHandleGetOrder_UserUnavailable(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: fmt.Errorf("%w: connection refused", userclient.ErrUnavailable)}
+
+	order := getOrderWithUser(t, store, "1")
+	if order.UserLookup != UserLookupUnavailable {
+		t.Errorf("Expected user_lookup %q, got %q", UserLookupUnavailable, order.UserLookup)
+	}
+	if order.UserName != "" || order.UserEmail != "" {
+		t.Errorf("Expected no user data, got %s <%s>", order.UserName, order.UserEmail)
+	}
+}
+
+func TestHandleGetOrder_UserNotFound(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: fmt.Errorf("%w: id 1", userclient.ErrNotFound)}
+
+	order := getOrderWithUser(t, store, "1")
+	if order.UserLookup != UserLookupNotFound {
+		t.Errorf("Expected user_lookup %q, got %q", UserLookupNotFound, order.UserLookup)
+	}
+}
+
+func postOrder(t *testing.T, store *OrderStore, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest("POST", "/orders", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
 	}
+	rr := httptest.NewRecorder()
+	store.handleCreateOrder(rr, req)
+	return rr
+}
+
+func TestHandleCreateOrder_UnknownUser(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: fmt.Errorf("%w: id 42", userclient.ErrNotFound)}
 	
-	if user == nil {
-		t.Fatal("Expected user data, got nil")
+	rr := postOrder(t, store, `{"user_id":42,"product":"Pen","quantity":1,"price":2.5}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
 	}
+	if len(store.orders) != 2 {
+		t.Errorf("Expected no order to be created, got %d orders", len(store.orders))
+	}
+}
+
+func TestHandleCreateOrder_SuspendedUser(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1, Status: userclient.StatusSuspended}}
 	
-	if user.ID != 1 {
-		t.Errorf("Expected user ID 1, got %d", user.ID)
+	rr := postOrder(t, store, `{"user_id":1,"product":"Pen","quantity":1,"price":2.5}`)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+	}
+	if len(store.orders) != 2 {
+		t.Errorf("Expected no order to be created, got %d orders", len(store.orders))
 	}
+}
+
+func TestHandleCreateOrder_UserServiceDownReject(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: fmt.Errorf("%w: connection refused", userclient.ErrUnavailable)}
+	store.userCheckPolicy = UserCheckReject
 	
-	expectedName := "User 1"
-	if user.Name != expectedName {
-		t.Errorf("Expected name '%s', got %s", expectedName, user.Name)
+	rr := postOrder(t, store, `{"user_id":1,"product":"Pen","quantity":1,"price":2.5}`)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if len(store.orders) != 2 {
+		t.Errorf("Expected no order to be created, got %d orders", len(store.orders))
 	}
+}
+
+func TestHandleCreateOrder_UserServiceDownAccept(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: fmt.Errorf("%w: connection refused", userclient.ErrUnavailable)}
+	store.userCheckPolicy = UserCheckAccept
 	
-	expectedEmail := "user1@example.com"
-	if user.Email != expectedEmail {
-		t.Errorf("Expected email '%s', got %s", expectedEmail, user.Email)
+	rr := postOrder(t, store, `{"user_id":1,"product":"Pen","quantity":1,"price":2.5}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
 	}
-} 
+	var order Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if !order.NeedsUserCheck {
+		t.Error("Expected order to be flagged for a later user check")
+	}
+	if stored, _ := store.GetOrder(order.ID); !stored.NeedsUserCheck {
+		t.Error("Expected stored order to be flagged for a later user check")
+	}
+}
+
+func TestUserCheckPolicyFromEnv(t *testing.T) {
+	t.Setenv("USER_CHECK_POLICY", "accept")
+	if policy := userCheckPolicyFromEnv(); policy != UserCheckAccept {
+		t.Errorf("Expected %q, got %q", UserCheckAccept, policy)
+	}
+	t.Setenv("USER_CHECK_POLICY", "bogus")
+	if policy := userCheckPolicyFromEnv(); policy != UserCheckReject {
+		t.Errorf("Expected %q, got %q", UserCheckReject, policy)
+	}
+}
 
 func TestHandleGetOrder_InvalidID(t *testing.T) {
 	store := NewOrderStore()
@@ -456,6 +710,54 @@ func TestHandleUpdateOrderStatus_InvalidStatus(t *testing.T) {
 	}
 }
 
+func TestHandleUpdateOrderStatus_InvalidTransition(t *testing.T) {
+	store := NewOrderStore()
+	req := httptest.NewRequest("PUT", "/orders/1/status", bytes.NewBufferString(`{"status":"delivered"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	store.handleUpdateOrderStatus(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+	}
+	if order, _ := store.GetOrder(1); order.Status != "pending" {
+		t.Errorf("Expected order to stay pending, got %s", order.Status)
+	}
+}
+
+func TestHandleGetOrderHistory(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+	req := httptest.NewRequest("PUT", "/orders/1/status", bytes.NewBufferString(`{"status":"processing","reason":"picked"}`))
+	req.Header.Set("X-Actor", "ops@example.com")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+	store.handleUpdateOrderStatus(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/orders/1/history", nil), map[string]string{"id": "1"})
+	rr = httptest.NewRecorder()
+	store.handleGetOrderHistory(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var history []StatusChange
+	if err := json.Unmarshal(rr.Body.Bytes(), &history); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if len(history) != 2 || history[1].Actor != "ops@example.com" || history[1].Reason != "picked" || history[1].To != "processing" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+	
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/orders/999/history", nil), map[string]string{"id": "999"})
+	rr = httptest.NewRecorder()
+	store.handleGetOrderHistory(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
 func TestHandleUpdateOrderStatus_InvalidJSON(t *testing.T) {
 	store := NewOrderStore()
 	req, err := http.NewRequest("PUT", "/orders/1/status", bytes.NewBuffer([]byte(`notjson`)))
@@ -493,31 +795,295 @@ func TestAuthorHandler(t *testing.T) {
 func TestCORSPreflight(t *testing.T) {
 	store := NewOrderStore()
 	r := mux.NewRouter()
-	r.HandleFunc("/orders", store.handleGetOrders).Methods("GET", "OPTIONS") // tambahkan OPTIONS agar middleware dijalankan
-	// Tambahkan CORS middleware
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	})
+	r.HandleFunc("/orders", store.handleGetOrders).Methods("GET")
+	r.Handle("/orders", http.HandlerFunc(store.handleCreateOrder)).Methods("POST")
+	handler := httpx.CORSMiddleware(defaultCORSConfig, r)(r)
 
 	req, err := http.NewRequest("OPTIONS", "/orders", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("Origin", "https://shop.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type, Idempotency-Key")
 	rr := httptest.NewRecorder()
-	r.ServeHTTP(rr, req)
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, status)
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, status)
 	}
 	if rr.Header().Get("Access-Control-Allow-Origin") != "*" {
 		t.Errorf("CORS header missing")
 	}
-} 
\ No newline at end of file
+	if methods := rr.Header().Get("Access-Control-Allow-Methods"); methods != "GET, POST" {
+		t.Errorf("Expected the methods of /orders, got %q", methods)
+	}
+	if headers := rr.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(headers, httpx.IdempotencyKeyHeader) {
+		t.Errorf("Expected Idempotency-Key to be allowed, got %q", headers)
+	}
+} 
+func servePayment(t *testing.T, handler http.HandlerFunc, id, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/orders/"+id+"/payment", bytes.NewBufferString(body))
+	req.Header.Set("X-Actor", "checkout")
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestHandlePaymentEndpoints(t *testing.T) {
+	store := NewOrderStore()
+	authorize := store.handlePaymentAction("/orders/{id}/payment/authorize", store.authorizePayment)
+	capture := store.handlePaymentAction("/orders/{id}/payment/capture", store.capturePayment)
+	refund := store.handlePaymentAction("/orders/{id}/payment/refund", store.refundPayment)
+	
+	if rr := servePayment(t, capture, "1", ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected capture before authorization to return %d, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := servePayment(t, authorize, "1", "nope"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid JSON to return %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := servePayment(t, authorize, "1", `{"method":"cash"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown method to return %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if rr := servePayment(t, authorize, "999", `{"method":"credit_card"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown order to return %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	
+	if rr := servePayment(t, authorize, "1", `{"method":"credit_card"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected authorization to return %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr := servePayment(t, capture, "1", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected capture to return %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var order Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != StatusConfirmed || order.PaymentStatus != PaymentStatusCompleted || order.Payment.Method != "credit_card" {
+		t.Errorf("Expected a paid, confirmed order, got %+v", order)
+	}
+	if history, _ := store.GetOrderHistory(1); len(history) != 1 || history[0].Actor != "checkout" {
+		t.Errorf("Expected the confirmation to be attributed to the caller, got %+v", history)
+	}
+	
+	if rr := servePayment(t, refund, "1", ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected refund of an active order to return %d, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestHandlePaymentDeclined(t *testing.T) {
+	store := NewOrderStore()
+	fake := payment.NewFake()
+	fake.Limit = 100
+	store.payments = fake
+	authorize := store.handlePaymentAction("/orders/{id}/payment/authorize", store.authorizePayment)
+	
+	if rr := servePayment(t, authorize, "2", `{"method":"debit_card"}`); rr.Code != http.StatusPaymentRequired {
+		t.Errorf("Expected a declined payment to return %d, got %d", http.StatusPaymentRequired, rr.Code)
+	}
+}
+
+func TestHandleCreateOrderWithShipping(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	
+	body := `{"user_id":1,"items":[{"product":"Lamp","quantity":2,"unit_price":"25.00"}],"shipping":{"address":"1 Main St","city":"Springfield","country":"US","method":"standard"}}`
+	rr := httptest.NewRecorder()
+	store.handleCreateOrder(rr, httptest.NewRequest("POST", "/orders", bytes.NewBufferString(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	
+	var order Order
+	if err := json.Unmarshal(rr.Body.Bytes(), &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Shipping == nil || order.Shipping.Cost != usd(549) || order.Total != usd(5549) {
+		t.Errorf("Expected the shipping cost in the total, got %+v", order)
+	}
+	
+	body = `{"user_id":1,"items":[{"product":"Lamp","quantity":1,"unit_price":"25.00"}],"shipping":{"method":"express"}}`
+	rr = httptest.NewRecorder()
+	store.handleCreateOrder(rr, httptest.NewRequest("POST", "/orders", bytes.NewBufferString(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d without an address, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleUpdateOrderStatus_Shipped(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+	if _, err := store.TransitionOrder(1, StatusProcessing, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+	
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"status":"shipped"}`, http.StatusConflict},
+		{`{"status":"shipped","tracking_number":"no"}`, http.StatusBadRequest},
+		{`{"status":"shipped","tracking_number":"1Z999AA10123456784"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", "/orders/1/status", bytes.NewBufferString(tt.body))
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+		store.handleUpdateOrderStatus(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected status code %d, got %d", tt.body, tt.want, rr.Code)
+		}
+	}
+	
+	if order, _ := store.GetOrder(1); order.Status != StatusShipped || order.Shipping.TrackingNumber != "1Z999AA10123456784" {
+		t.Errorf("Expected a shipped order with its tracking number, got %+v", order)
+	}
+}
+
+func TestHandleCancelOrder(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+	
+	tests := []struct {
+		id   string
+		body string
+		want int
+	}{
+		{"1", `not json`, http.StatusBadRequest},
+		{"1", `{"reason":"bored"}`, http.StatusBadRequest},
+		{"999", `{"reason":"customer_request"}`, http.StatusNotFound},
+		{"1", `{"reason":"customer_request","note":"ordered twice"}`, http.StatusOK},
+		{"1", `{"reason":"customer_request"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/orders/"+tt.id+"/cancel", bytes.NewBufferString(tt.body))
+		req.Header.Set("X-Actor", "support")
+		req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+		rr := httptest.NewRecorder()
+		store.handleCancelOrder(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected status code %d, got %d", tt.id, tt.body, tt.want, rr.Code)
+		}
+	}
+	
+	body := `{"reason":"other","note":"` + strings.Repeat("x", maxCancelNoteLength+1) + `"}`
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/orders/2/cancel", bytes.NewBufferString(body)), map[string]string{"id": "2"})
+	rr := httptest.NewRecorder()
+	store.handleCancelOrder(rr, req)
+	var problem httpx.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil || len(problem.Errors) != 1 || problem.Errors[0].Field != "note" {
+		t.Errorf("Expected a long note to be reported on the note field, got %s", rr.Body.String())
+	}
+	
+	order, _ := store.GetOrder(1)
+	if c := order.Cancellation; c == nil || c.Reason != CancelReasonCustomerRequest || c.Note != "ordered twice" || c.Actor != "support" {
+		t.Errorf("Expected the cancellation to be recorded, got %+v", order.Cancellation)
+	}
+	if order.PaymentStatus != PaymentStatusRefunded {
+		t.Errorf("Expected the payment to be refunded, got %s", order.PaymentStatus)
+	}
+}
+
+func TestHandleGetOrderMetrics(t *testing.T) {
+	store := newQueryTestStore(t)
+	setPaymentStatus(t, store, PaymentStatusCompleted, 2, 4)
+	
+	rr := httptest.NewRecorder()
+	store.handleGetOrderMetrics(rr, httptest.NewRequest("GET", "/orders/metrics?start=2025-01-01T00:00:00Z&end=2025-01-03T00:00:00Z", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	
+	var report OrderStatsReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Bucket != BucketDay || len(report.Buckets) != 2 || report.Buckets[0].TotalOrders != 5 || report.Buckets[1].TotalOrders != 0 {
+		t.Errorf("Expected every order in the first of two daily buckets, got %+v", report)
+	}
+	if report.Summary.CancelledOrders != 1 || len(report.Summary.Revenue) != 1 || report.Summary.Revenue[0] != usd(32500) {
+		t.Errorf("Unexpected summary %+v", report.Summary)
+	}
+	
+	for _, query := range []string{"start=bogus", "bucket=year", "start=2025-01-02T00:00:00Z&end=2025-01-01T00:00:00Z"} {
+		rr := httptest.NewRecorder()
+		store.handleGetOrderMetrics(rr, httptest.NewRequest("GET", "/orders/metrics?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHandleCreateOrderIdempotent(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{user: &userclient.User{ID: 1}}
+	handler := httpx.NewIdempotency(time.Hour).Middleware(http.HandlerFunc(store.handleCreateOrder))
+	
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(httpx.IdempotencyKeyHeader, "order-key-1")
+		req.Header.Set("Authorization", "Bearer client-1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	
+	body := `{"user_id": 1, "product": "Retry Product", "quantity": 1, "price": 10}`
+	first := create(body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, first.Code)
+	}
+	retry := create(body)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first response to be replayed, got %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(httpx.IdempotentReplayedHeader) != "true" {
+		t.Error("Expected the replayed response to be marked")
+	}
+	if len(store.orders) != 3 {
+		t.Errorf("Expected one order to be created, got %d orders", len(store.orders))
+	}
+	
+	if rr := create(`{"user_id": 1, "product": "Other Product", "quantity": 1, "price": 10}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d for a different body, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestHandleCreateOrderProblemDetails(t *testing.T) {
+	store := NewOrderStore()
+	store.users = stubUserFetcher{err: userclient.ErrNotFound}
+	
+	tests := []struct {
+		body   string
+		status int
+		code   httpx.ErrorCode
+		field  string
+	}{
+		{`not json`, http.StatusBadRequest, httpx.CodeInvalidRequest, ""},
+		{`{"product":"Lamp","quantity":1,"price":10}`, http.StatusBadRequest, httpx.CodeValidationFailed, "user_id"},
+		{`{"user_id":1,"currency":"XXX","product":"Lamp","quantity":1,"price":10}`, http.StatusBadRequest, httpx.CodeValidationFailed, "currency"},
+		{`{"user_id":1,"items":[{"product":"Lamp","quantity":0,"unit_price":10}]}`, http.StatusBadRequest, httpx.CodeValidationFailed, "items"},
+		{`{"user_id":42,"product":"Lamp","quantity":1,"price":10}`, http.StatusUnprocessableEntity, httpx.CodeValidationFailed, "user_id"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		store.handleCreateOrder(rr, httptest.NewRequest("POST", "/orders", strings.NewReader(tt.body)))
+		if rr.Code != tt.status || rr.Header().Get("Content-Type") != httpx.ProblemContentType {
+			t.Errorf("%s: expected a %d problem, got %d %s", tt.body, tt.status, rr.Code, rr.Header().Get("Content-Type"))
+			continue
+		}
+		var problem httpx.Problem
+		if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+			t.Fatal("Failed to parse JSON response")
+		}
+		if problem.Code != tt.code || problem.Status != tt.status {
+			t.Errorf("%s: expected code %s, got %+v", tt.body, tt.code, problem)
+		}
+		if tt.field != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field) {
+			t.Errorf("%s: expected a %s field error, got %+v", tt.body, tt.field, problem.Errors)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// defaultCurrency is used when a request does not name a currency
+const defaultCurrency = "USD"
+
+// currencyExponents maps each supported ISO 4217 code to the number of minor
+// unit digits, e.g. cents for USD and none for JPY
+var currencyExponents = map[string]int{
+	"AUD": 2,
+	"BHD": 3,
+	"CAD": 2,
+	"CHF": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"IDR": 2,
+	"JPY": 0,
+	"KWD": 3,
+	"SGD": 2,
+	"USD": 2,
+}
+
+// ErrInvalidAmount is returned when an amount is malformed, negative, more
+// precise than its currency allows or too large
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ErrUnsupportedCurrency is returned for currency codes outside currencyExponents
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// ErrCurrencyMismatch is returned when amounts in different currencies are combined
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Money is an exact amount in the minor units of an ISO 4217 currency, e.g.
+// {Amount: 999, Currency: "USD"} is 9.99 US dollars
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// normalizeCurrency upper-cases code and checks that it is supported
+func normalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := currencyExponents[code]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedCurrency, code)
+	}
+	return code, nil
+}
+
+// NewMoney returns amount minor units of currency
+func NewMoney(amount int64, currency string) (Money, error) {
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if amount < 0 {
+		return Money{}, fmt.Errorf("%w: negative amount", ErrInvalidAmount)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// ParseMoney reads a plain decimal such as "12", "12.5" or "12.50" in
+// currency. Values more precise than the currency's minor unit are rejected
+// rather than rounded.
+func ParseMoney(s, currency string) (Money, error) {
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	exponent := currencyExponents[currency]
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || !isDigits(whole) || (hasPoint && (frac == "" || !isDigits(frac))) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(frac) > exponent {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimal places for %s", ErrInvalidAmount, s, exponent, currency)
+	}
+
+	scale := pow10(exponent)
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/scale {
+		return Money{}, fmt.Errorf("%w: %q is too large", ErrInvalidAmount, s)
+	}
+	minor := int64(0)
+	if frac != "" {
+		minor, _ = strconv.ParseInt(frac+strings.Repeat("0", exponent-len(frac)), 10, 64)
+	}
+	if units*scale > math.MaxInt64-minor {
+		return Money{}, fmt.Errorf("%w: %q is too large", ErrInvalidAmount, s)
+	}
+	return Money{Amount: units*scale + minor, Currency: currency}, nil
+}
+
+// moneyFromFloat converts a floating-point amount, such as a double from
+// order.proto, using its shortest exact representation
+func moneyFromFloat(f float64, currency string) (Money, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Money{}, fmt.Errorf("%w: %v", ErrInvalidAmount, f)
+	}
+	return ParseMoney(strconv.FormatFloat(f, 'f', -1, 64), currency)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// Add returns m+other, failing on overflow or when the currencies differ
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	if other.Amount > math.MaxInt64-m.Amount {
+		return Money{}, fmt.Errorf("%w: total is too large", ErrInvalidAmount)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Mul returns m multiplied by a non-negative quantity, failing on overflow
+func (m Money) Mul(quantity int) (Money, error) {
+	if quantity < 0 {
+		return Money{}, fmt.Errorf("%w: negative quantity", ErrInvalidAmount)
+	}
+	if quantity != 0 && m.Amount > math.MaxInt64/int64(quantity) {
+		return Money{}, fmt.Errorf("%w: total is too large", ErrInvalidAmount)
+	}
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}, nil
+}
+
+// Decimal formats m in major units with exactly as many fractional digits as
+// its currency has, e.g. "9.99" for USD or "1000" for JPY
+func (m Money) Decimal() string {
+	exponent := currencyExponents[m.Currency]
+	if exponent == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+	scale := pow10(exponent)
+	return fmt.Sprintf("%d.%0*d", m.Amount/scale, exponent, m.Amount%scale)
+}
+
+// Float64 approximates m in major units for consumers that only accept
+// floating point, such as the double fields in order.proto
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / float64(pow10(currencyExponents[m.Currency]))
+}
+
+// String formats m for logs, e.g. "9.99 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// moneyJSON is the wire form of Money. The amount is a decimal string so
+// that it survives JSON parsers that read numbers as float64.
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON writes m as {"amount": "9.99", "currency": "USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON reads the form written by MarshalJSON. The amount may also be
+// a JSON number; it is parsed as text, never through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+	}
+	parsed, err := ParseMoney(unquoteAmount(wire.Amount), wire.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// unquoteAmount returns the text of a JSON number or string
+func unquoteAmount(data []byte) string {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+// PriceInput is a price in a create request: either a Money object or a bare
+// decimal number or string in the currency of the order
+type PriceInput struct {
+	money *Money
+	text  string
+}
+
+// UnmarshalJSON accepts {"amount": ..., "currency": ...}, 9.99 or "9.99"
+func (p *PriceInput) UnmarshalJSON(data []byte) error {
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var money Money
+		if err := json.Unmarshal(data, &money); err != nil {
+			return err
+		}
+		*p = PriceInput{money: &money}
+		return nil
+	}
+	*p = PriceInput{text: unquoteAmount(data)}
+	return nil
+}
+
+// IsZero reports whether no price was given
+func (p PriceInput) IsZero() bool {
+	return p.money == nil && p.text == ""
+}
+
+// In resolves the price in currency, rejecting a Money object in another
+// currency
+func (p PriceInput) In(currency string) (Money, error) {
+	if p.money == nil {
+		return ParseMoney(p.text, currency)
+	}
+	if p.money.Currency != currency {
+		return Money{}, fmt.Errorf("%w: price in %s for an order in %s", ErrCurrencyMismatch, p.money.Currency, currency)
+	}
+	return *p.money, nil
+}
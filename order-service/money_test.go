@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// usd returns amount cents
+func usd(amount int64) Money {
+	return Money{Amount: amount, Currency: "USD"}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     Money
+	}{
+		{"0", "USD", usd(0)},
+		{"12.5", "usd", usd(1250)},
+		{"0.07", "EUR", Money{Amount: 7, Currency: "EUR"}},
+		{"1500", "JPY", Money{Amount: 1500, Currency: "JPY"}},
+		{"1.234", "KWD", Money{Amount: 1234, Currency: "KWD"}},
+		{"92233720368547758.07", "USD", usd(9223372036854775807)},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in, tt.currency)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q, %q) = %v, %v; want %v", tt.in, tt.currency, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []struct{ in, currency string }{
+		{"", "USD"}, {"-1", "USD"}, {"1.", "USD"}, {".5", "USD"}, {"1e2", "USD"},
+		{"1.005", "USD"}, {"1.5", "JPY"}, {"92233720368547758.08", "USD"},
+	} {
+		if _, err := ParseMoney(bad.in, bad.currency); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("ParseMoney(%q, %q): expected ErrInvalidAmount, got %v", bad.in, bad.currency, err)
+		}
+	}
+	if _, err := ParseMoney("1", "XYZ"); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("Expected ErrUnsupportedCurrency, got %v", err)
+	}
+}
+
+func TestMoneyArithmeticIsExact(t *testing.T) {
+	sum, err := usd(10).Add(usd(20))
+	if err != nil || sum.Decimal() != "0.30" {
+		t.Errorf("Expected 0.10 + 0.20 = 0.30, got %s (%v)", sum, err)
+	}
+
+	product, err := usd(1999).Mul(3)
+	if err != nil || product.Decimal() != "59.97" {
+		t.Errorf("Expected 19.99 x 3 = 59.97, got %s (%v)", product, err)
+	}
+
+	if _, err := usd(1).Add(Money{Amount: 1, Currency: "EUR"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch, got %v", err)
+	}
+	if _, err := usd(1 << 62).Mul(4); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected overflow to fail, got %v", err)
+	}
+	if _, err := usd(1 << 62).Add(usd(1 << 62)); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected overflow to fail, got %v", err)
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := map[Money]string{
+		usd(5):                               "0.05",
+		usd(99999):                           "999.99",
+		{Amount: 1500, Currency: "JPY"}:      "1500",
+		{Amount: 1005, Currency: "KWD"}:      "1.005",
+		{Amount: 1<<53 + 1, Currency: "USD"}: "90071992547409.93",
+	}
+	for m, want := range tests {
+		if got := m.Decimal(); got != want {
+			t.Errorf("%d %s: expected %s, got %s", m.Amount, m.Currency, want, got)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	original := usd(1<<53 + 1)
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"amount":"90071992547409.93","currency":"USD"}` {
+		t.Errorf("Unexpected encoding %s", data)
+	}
+
+	var decoded Money
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != original {
+		t.Errorf("Expected %v after a round trip, got %v (%v)", original, decoded, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount":0.1,"currency":"EUR"}`), &decoded); err != nil || decoded.Amount != 10 {
+		t.Errorf("Expected a JSON number to parse exactly, got %v (%v)", decoded, err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"1.50","currency":"JPY"}`), &decoded); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":"1","currency":"ABC"}`), &decoded); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("Expected ErrUnsupportedCurrency, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Cancellation reason codes
+const (
+	CancelReasonCustomerRequest = "customer_request"
+	CancelReasonOutOfStock      = "out_of_stock"
+	CancelReasonPaymentFailed   = "payment_failed"
+	CancelReasonFraudSuspected  = "fraud_suspected"
+	CancelReasonDuplicateOrder  = "duplicate_order"
+	CancelReasonAddressInvalid  = "address_invalid"
+	CancelReasonOther           = "other"
+)
+
+// cancelReasons lists the accepted cancellation reason codes
+var cancelReasons = map[string]bool{
+	CancelReasonCustomerRequest: true,
+	CancelReasonOutOfStock:      true,
+	CancelReasonPaymentFailed:   true,
+	CancelReasonFraudSuspected:  true,
+	CancelReasonDuplicateOrder:  true,
+	CancelReasonAddressInvalid:  true,
+	CancelReasonOther:           true,
+}
+
+// maxCancelNoteLength caps the free-text note attached to a cancellation
+const maxCancelNoteLength = 500
+
+// ErrInvalidCancelReason is returned for reason codes outside cancelReasons
+var ErrInvalidCancelReason = errors.New("invalid cancellation reason")
+
+// ErrCancelNoteTooLong is returned for notes over maxCancelNoteLength
+var ErrCancelNoteTooLong = errors.New("cancellation note too long")
+
+// Cancellation records who cancelled an order, when and why
+type Cancellation struct {
+	Reason string `json:"reason"`
+	Note   string `json:"note,omitempty"`
+	Actor  string `json:"actor"`
+	At     string `json:"at"`
+}
+
+// CancelHook is called with an order after it has been cancelled, e.g. to
+// refund its payment or release its stock. Errors are logged and do not undo
+// the cancellation.
+type CancelHook func(ctx context.Context, order *Order) error
+
+// OnCancel registers hook to run after every cancellation, in registration
+// order. Hooks should be registered before the store serves requests.
+func (s *OrderStore) OnCancel(hook CancelHook) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cancelHooks = append(s.cancelHooks, hook)
+}
+
+// CancelOrder cancels an order on behalf of actor with a reason code from
+// cancelReasons and an optional note, then runs the cancel hooks
+func (s *OrderStore) CancelOrder(ctx context.Context, id int, reason, note, actor string) (*Order, error) {
+	reason = strings.ToLower(strings.TrimSpace(reason))
+	note = strings.TrimSpace(note)
+	if !cancelReasons[reason] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCancelReason, reason)
+	}
+	if len(note) > maxCancelNoteLength {
+		return nil, fmt.Errorf("%w: note is longer than %d characters", ErrCancelNoteTooLong, maxCancelNoteLength)
+	}
+
+	changeReason := reason
+	if note != "" {
+		changeReason += ": " + note
+	}
+	order, err := s.transitionOrder(id, StatusCancelled, actor, changeReason, func(order *Order) {
+		order.Cancellation = &Cancellation{Reason: reason, Note: note}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.runCancelHooks(ctx, order), nil
+}
+
+// runCancelHooks runs the cancel hooks for a cancelled order and returns its
+// latest state. The hooks outlive a cancelled ctx so that a client hanging
+// up does not abandon a refund halfway.
+func (s *OrderStore) runCancelHooks(ctx context.Context, order *Order) *Order {
+	s.mutex.RLock()
+	hooks := s.cancelHooks
+	s.mutex.RUnlock()
+
+	ctx = context.WithoutCancel(ctx)
+	for _, hook := range hooks {
+		if err := hook(ctx, order); err != nil {
+			log.Printf("Cancel hook failed for order %d: %v", order.ID, err)
+		}
+	}
+
+	if latest, exists := s.GetOrder(order.ID); exists {
+		return latest
+	}
+	return order
+}
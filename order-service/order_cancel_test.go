@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"order-service/internal/payment"
+)
+
+func TestCancelOrder(t *testing.T) {
+	ctx := context.Background()
+	store := NewOrderStore()
+
+	var hooked []int
+	store.OnCancel(func(ctx context.Context, order *Order) error {
+		hooked = append(hooked, order.ID)
+		return errors.New("stock service unavailable")
+	})
+
+	if _, err := store.CancelOrder(ctx, 1, "bored", "", "alice"); !errors.Is(err, ErrInvalidCancelReason) {
+		t.Errorf("Expected ErrInvalidCancelReason for an unknown code, got %v", err)
+	}
+	if _, err := store.CancelOrder(ctx, 1, CancelReasonOther, strings.Repeat("x", maxCancelNoteLength+1), "alice"); !errors.Is(err, ErrCancelNoteTooLong) {
+		t.Errorf("Expected ErrCancelNoteTooLong for a long note, got %v", err)
+	}
+	if _, err := store.CancelOrder(ctx, 999, CancelReasonOther, "", "alice"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+
+	order, err := store.CancelOrder(ctx, 1, " Out_Of_Stock ", "supplier delay", "alice")
+	if err != nil {
+		t.Fatalf("Expected a failing hook not to undo the cancellation, got %v", err)
+	}
+	c := order.Cancellation
+	if order.Status != StatusCancelled || c == nil || c.Reason != CancelReasonOutOfStock || c.Note != "supplier delay" || c.Actor != "alice" || c.At == "" {
+		t.Errorf("Expected the cancellation to be recorded, got %+v", order)
+	}
+	if len(hooked) != 1 || hooked[0] != 1 {
+		t.Errorf("Expected the hook to run once for order 1, got %v", hooked)
+	}
+	history, _ := store.GetOrderHistory(1)
+	if last := history[len(history)-1]; last.To != StatusCancelled || last.Reason != "out_of_stock: supplier delay" {
+		t.Errorf("Expected the reason in the history, got %+v", last)
+	}
+
+	if _, err := store.CancelOrder(ctx, 1, CancelReasonOther, "", "alice"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected a cancelled order not to be cancelled again, got %v", err)
+	}
+	if len(hooked) != 1 {
+		t.Errorf("Expected the hook not to run for a rejected cancellation, got %v", hooked)
+	}
+
+	// Cancelling through a plain status change records reason code "other"
+	order, err = store.TransitionOrder(2, StatusCancelled, "bob", "changed mind")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := order.Cancellation; c.Reason != CancelReasonOther || c.Note != "changed mind" || c.Actor != "bob" || len(hooked) != 2 {
+		t.Errorf("Expected a status change to cancel like CancelOrder, got %+v and hooks %v", c, hooked)
+	}
+}
+
+func TestCancelOrderReleasesPayment(t *testing.T) {
+	ctx := context.Background()
+	store := NewOrderStore()
+
+	// A captured payment is refunded
+	payOrder(t, store, 1)
+	order, err := store.CancelOrder(ctx, 1, CancelReasonCustomerRequest, "", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PaymentStatus != PaymentStatusRefunded || order.Payment.RefundedAt == "" {
+		t.Errorf("Expected the payment to be refunded, got %+v", order)
+	}
+
+	// An authorization that was never captured is cancelled
+	if _, err := store.AuthorizePayment(ctx, 2, payment.MethodCreditCard); err != nil {
+		t.Fatal(err)
+	}
+	order, err = store.CancelOrder(ctx, 2, CancelReasonFraudSuspected, "", "risk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PaymentStatus != PaymentStatusCancelled {
+		t.Errorf("Expected the authorization to be cancelled, got %s", order.PaymentStatus)
+	}
+
+	// Unpaid orders are left alone
+	created, err := store.CreateOrder(3, singleItem("Desk", 1, usd(15000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order, err = store.CancelOrder(ctx, created.ID, CancelReasonDuplicateOrder, "", "bob"); err != nil || order.PaymentStatus != PaymentStatusPending {
+		t.Errorf("Expected an unpaid order to stay pending payment, got %+v, %v", order, err)
+	}
+}
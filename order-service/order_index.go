@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// idSet is a set of order IDs
+type idSet map[int]struct{}
+
+// indexEntry places an order at a numeric key within a sortedIndex
+type indexEntry struct {
+	key int64
+	id  int
+}
+
+// sortedIndex keeps entries ordered by key and then ID for range lookups
+type sortedIndex []indexEntry
+
+func (e indexEntry) less(key int64, id int) bool {
+	return e.key < key || (e.key == key && e.id < id)
+}
+
+// insert adds an entry, keeping the index sorted
+func (x *sortedIndex) insert(key int64, id int) {
+	i := sort.Search(len(*x), func(i int) bool { return !(*x)[i].less(key, id) })
+	*x = append(*x, indexEntry{})
+	copy((*x)[i+1:], (*x)[i:])
+	(*x)[i] = indexEntry{key: key, id: id}
+}
+
+// remove deletes an entry if present
+func (x *sortedIndex) remove(key int64, id int) {
+	i := sort.Search(len(*x), func(i int) bool { return !(*x)[i].less(key, id) })
+	if i < len(*x) && (*x)[i].key == key && (*x)[i].id == id {
+		*x = append((*x)[:i], (*x)[i+1:]...)
+	}
+}
+
+// between returns the IDs whose key lies in [min, max]
+func (x sortedIndex) between(min, max int64) []int {
+	lo := sort.Search(len(x), func(i int) bool { return x[i].key >= min })
+	hi := sort.Search(len(x), func(i int) bool { return x[i].key > max })
+	ids := make([]int, 0, hi-lo)
+	for _, entry := range x[lo:hi] {
+		ids = append(ids, entry.id)
+	}
+	return ids
+}
+
+// orderIndex maintains secondary indexes over the orders in an OrderStore so
+// that filtered queries only visit matching orders. Totals are indexed per
+// currency in minor units, since amounts in different currencies do not
+// compare. It is guarded by the store's mutex.
+type orderIndex struct {
+	byUser    map[int]idSet
+	byStatus  map[string]idSet
+	byPayment map[string]idSet
+	byCreated sortedIndex
+	byAmount  map[string]*sortedIndex
+}
+
+func newOrderIndex() *orderIndex {
+	return &orderIndex{
+		byUser:    make(map[int]idSet),
+		byStatus:  make(map[string]idSet),
+		byPayment: make(map[string]idSet),
+		byAmount:  make(map[string]*sortedIndex),
+	}
+}
+
+// add indexes order
+func (x *orderIndex) add(order *Order) {
+	addToSet(x.byUser, order.UserID, order.ID)
+	addToSet(x.byStatus, order.Status, order.ID)
+	addToSet(x.byPayment, order.PaymentStatus, order.ID)
+	x.byCreated.insert(createdKey(order), order.ID)
+	amounts, ok := x.byAmount[order.Total.Currency]
+	if !ok {
+		amounts = &sortedIndex{}
+		x.byAmount[order.Total.Currency] = amounts
+	}
+	amounts.insert(order.Total.Amount, order.ID)
+}
+
+// remove drops order from every index
+func (x *orderIndex) remove(order *Order) {
+	removeFromSet(x.byUser, order.UserID, order.ID)
+	removeFromSet(x.byStatus, order.Status, order.ID)
+	removeFromSet(x.byPayment, order.PaymentStatus, order.ID)
+	x.byCreated.remove(createdKey(order), order.ID)
+	if amounts, ok := x.byAmount[order.Total.Currency]; ok {
+		amounts.remove(order.Total.Amount, order.ID)
+		if len(*amounts) == 0 {
+			delete(x.byAmount, order.Total.Currency)
+		}
+	}
+}
+
+func addToSet[K comparable](index map[K]idSet, key K, id int) {
+	set, ok := index[key]
+	if !ok {
+		set = make(idSet)
+		index[key] = set
+	}
+	set[id] = struct{}{}
+}
+
+func removeFromSet[K comparable](index map[K]idSet, key K, id int) {
+	if set, ok := index[key]; ok {
+		delete(set, id)
+		if len(set) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+// createdKey returns the creation time of order as Unix seconds
+func createdKey(order *Order) int64 {
+	created, err := time.Parse(time.RFC3339, order.Created)
+	if err != nil {
+		return 0
+	}
+	return created.Unix()
+}
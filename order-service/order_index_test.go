@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestSortedIndex(t *testing.T) {
+	var x sortedIndex
+	x.insert(30, 3)
+	x.insert(10, 1)
+	x.insert(20, 2)
+	x.insert(20, 4)
+
+	if got := fmt.Sprint(x.between(15, 30)); got != "[2 4 3]" {
+		t.Errorf("Expected [2 4 3], got %s", got)
+	}
+	x.remove(20, 2)
+	x.remove(99, 9)
+	if got := fmt.Sprint(x.between(0, 100)); got != "[1 4 3]" {
+		t.Errorf("Expected [1 4 3] after removal, got %s", got)
+	}
+}
+
+func TestOrderIndexFollowsUpdates(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+
+	if err := store.UpdateOrderStatus(1, "processing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.index.byStatus["pending"][1]; ok {
+		t.Error("Expected order 1 to leave the pending index")
+	}
+	if _, ok := store.index.byStatus["processing"][1]; !ok {
+		t.Error("Expected order 1 in the processing index")
+	}
+	if len(store.index.byCreated) != 2 || len(*store.index.byAmount["USD"]) != 2 {
+		t.Errorf("Expected one range entry per order, got %d and %d", len(store.index.byCreated), len(*store.index.byAmount["USD"]))
+	}
+
+	ids := []int{}
+	for _, order := range store.GetOrdersByUser(2) {
+		ids = append(ids, order.ID)
+	}
+	sort.Ints(ids)
+	if fmt.Sprint(ids) != "[2]" {
+		t.Errorf("Expected user 2 to own order 2, got %v", ids)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxOrderItems caps the number of line items in a single order
+const maxOrderItems = 100
+
+// ErrInvalidItems is returned when the line items of an order are missing or
+// malformed
+var ErrInvalidItems = errors.New("invalid order items")
+
+// OrderItem is a single line of an order. TotalPrice is always computed by
+// the server from UnitPrice and Quantity.
+type OrderItem struct {
+	ID         int               `json:"id"`
+	ProductID  string            `json:"product_id,omitempty"`
+	Product    string            `json:"product"`
+	Quantity   int               `json:"quantity"`
+	UnitPrice  Money             `json:"unit_price"`
+	TotalPrice Money             `json:"total_price"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// orderItemRequest is a line item in a create request. Prices may be bare
+// decimals in the order currency.
+type orderItemRequest struct {
+	ProductID  string            `json:"product_id"`
+	Product    string            `json:"product"`
+	Quantity   int               `json:"quantity"`
+	UnitPrice  PriceInput        `json:"unit_price"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// singleItem builds the line items of an order for one product, as created by
+// clients written before orders had line items
+func singleItem(product string, quantity int, price Money) []OrderItem {
+	return []OrderItem{{Product: product, Quantity: quantity, UnitPrice: price}}
+}
+
+// resolveItems converts requested line items into order items priced in currency
+func resolveItems(currency string, requests []orderItemRequest) ([]OrderItem, error) {
+	items := make([]OrderItem, 0, len(requests))
+	for i, req := range requests {
+		if req.UnitPrice.IsZero() {
+			return nil, fmt.Errorf("%w: item %d has no unit_price", ErrInvalidItems, i+1)
+		}
+		price, err := req.UnitPrice.In(currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: item %d: %w", ErrInvalidItems, i+1, err)
+		}
+		items = append(items, OrderItem{
+			ProductID:  req.ProductID,
+			Product:    req.Product,
+			Quantity:   req.Quantity,
+			UnitPrice:  price,
+			Attributes: req.Attributes,
+		})
+	}
+	return items, nil
+}
+
+// priceItems validates items, numbers them from 1 and computes each line
+// total and the order total. Every item must be priced in the same supported
+// currency. The input slice is not modified.
+func priceItems(items []OrderItem) ([]OrderItem, Money, error) {
+	if len(items) == 0 {
+		return nil, Money{}, fmt.Errorf("%w: at least one item is required", ErrInvalidItems)
+	}
+	if len(items) > maxOrderItems {
+		return nil, Money{}, fmt.Errorf("%w: at most %d items are allowed", ErrInvalidItems, maxOrderItems)
+	}
+
+	priced := make([]OrderItem, len(items))
+	total := Money{Currency: items[0].UnitPrice.Currency}
+	for i, item := range items {
+		switch {
+		case item.Product == "":
+			return nil, Money{}, fmt.Errorf("%w: item %d has no product", ErrInvalidItems, i+1)
+		case item.Quantity <= 0:
+			return nil, Money{}, fmt.Errorf("%w: item %d quantity must be positive", ErrInvalidItems, i+1)
+		case item.UnitPrice.Amount <= 0:
+			return nil, Money{}, fmt.Errorf("%w: item %d unit_price must be positive", ErrInvalidItems, i+1)
+		}
+		if _, ok := currencyExponents[item.UnitPrice.Currency]; !ok {
+			return nil, Money{}, fmt.Errorf("%w: item %d: %w %q", ErrInvalidItems, i+1, ErrUnsupportedCurrency, item.UnitPrice.Currency)
+		}
+
+		lineTotal, err := item.UnitPrice.Mul(item.Quantity)
+		if err != nil {
+			return nil, Money{}, fmt.Errorf("%w: item %d: %w", ErrInvalidItems, i+1, err)
+		}
+		if total, err = total.Add(lineTotal); err != nil {
+			return nil, Money{}, fmt.Errorf("%w: item %d: %w", ErrInvalidItems, i+1, err)
+		}
+
+		item.ID = i + 1
+		item.TotalPrice = lineTotal
+		priced[i] = item
+	}
+
+	return priced, total, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPriceItems(t *testing.T) {
+	input := []OrderItem{
+		{Product: "Pen", Quantity: 3, UnitPrice: usd(10), TotalPrice: usd(1)},
+		{Product: "Ink", Quantity: 2, UnitPrice: usd(1999)},
+	}
+	items, total, err := priceItems(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].ID != 1 || items[1].ID != 2 {
+		t.Errorf("Expected items numbered from 1, got %d and %d", items[0].ID, items[1].ID)
+	}
+	if items[0].TotalPrice != usd(30) || items[1].TotalPrice != usd(3998) || total != usd(4028) {
+		t.Errorf("Unexpected totals %s, %s and %s", items[0].TotalPrice, items[1].TotalPrice, total)
+	}
+	if input[0].TotalPrice != usd(1) {
+		t.Error("Expected the input items to be left unchanged")
+	}
+}
+
+func TestPriceItemsRejectsInvalidItems(t *testing.T) {
+	tests := map[string][]OrderItem{
+		"no items":      nil,
+		"no product":    {{Quantity: 1, UnitPrice: usd(100)}},
+		"zero quantity": {{Product: "Pen", UnitPrice: usd(100)}},
+		"zero price":    {{Product: "Pen", Quantity: 1}},
+		"unsupported":   {{Product: "Pen", Quantity: 1, UnitPrice: Money{Amount: 100, Currency: "XYZ"}}},
+		"mixed":         {{Product: "Pen", Quantity: 1, UnitPrice: usd(100)}, {Product: "Ink", Quantity: 1, UnitPrice: Money{Amount: 100, Currency: "EUR"}}},
+		"overflow":      {{Product: "Gold", Quantity: 1 << 40, UnitPrice: usd(1 << 40)}},
+		"too many":      make([]OrderItem, maxOrderItems+1),
+	}
+	for name, items := range tests {
+		if _, _, err := priceItems(items); !errors.Is(err, ErrInvalidItems) {
+			t.Errorf("%s: expected ErrInvalidItems, got %v", name, err)
+		}
+	}
+}
+
+func TestResolveItems(t *testing.T) {
+	var requests []orderItemRequest
+	body := `[{"product":"Pen","quantity":1,"unit_price":"1000"},{"product":"Ink","quantity":1,"unit_price":{"amount":250,"currency":"jpy"}}]`
+	if err := json.Unmarshal([]byte(body), &requests); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := resolveItems("JPY", requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].UnitPrice != (Money{Amount: 1000, Currency: "JPY"}) || items[1].UnitPrice != (Money{Amount: 250, Currency: "JPY"}) {
+		t.Errorf("Unexpected prices %s and %s", items[0].UnitPrice, items[1].UnitPrice)
+	}
+
+	if _, err := resolveItems("EUR", requests); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch, got %v", err)
+	}
+	if _, err := resolveItems("USD", []orderItemRequest{{Product: "Pen", Quantity: 1}}); !errors.Is(err, ErrInvalidItems) {
+		t.Errorf("Expected ErrInvalidItems for a missing price, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOrderStatusCollector(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+	if err := store.UpdateOrderStatus(1, "processing"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP orders_by_status Number of orders currently in each status
+# TYPE orders_by_status gauge
+orders_by_status{status="cancelled"} 0
+orders_by_status{status="confirmed"} 0
+orders_by_status{status="delivered"} 0
+orders_by_status{status="pending"} 1
+orders_by_status{status="processing"} 1
+orders_by_status{status="shipped"} 0
+`
+	if err := testutil.CollectAndCompare(NewOrderStatusCollector(store), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransitionsCounter(t *testing.T) {
+	store := NewOrderStore()
+	counter := orderTransitionsCounter.WithLabelValues("pending", "cancelled")
+	before := testutil.ToFloat64(counter)
+
+	if err := store.UpdateOrderStatus(2, "cancelled"); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("Expected one pending to cancelled transition, got %v", got)
+	}
+	if err := store.UpdateOrderStatus(2, "shipped"); err == nil {
+		t.Fatal("Expected cancelled order to reject shipping")
+	}
+	if got := testutil.ToFloat64(orderTransitionsCounter.WithLabelValues("cancelled", "shipped")); got != 0 {
+		t.Errorf("Expected rejected transitions not to be counted, got %v", got)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"order-service/internal/payment"
+)
+
+// PaymentInfo records the payment taken for an order at the provider
+type PaymentInfo struct {
+	ID             string `json:"id"`
+	Method         string `json:"method"`
+	Amount         Money  `json:"amount"`
+	TransactionRef string `json:"transaction_ref,omitempty"`
+	AuthorizedAt   string `json:"authorized_at,omitempty"`
+	CapturedAt     string `json:"captured_at,omitempty"`
+	RefundedAt     string `json:"refunded_at,omitempty"`
+}
+
+// ErrPaymentState is returned when an order's payment cannot move to the
+// requested state, e.g. capturing a payment that was never authorized
+var ErrPaymentState = errors.New("invalid payment state")
+
+// ErrInvalidPaymentMethod is returned for methods outside payment.Methods
+var ErrInvalidPaymentMethod = errors.New("invalid payment method")
+
+// paymentCapturedReason is recorded on the status change that confirms an order
+const paymentCapturedReason = "payment captured"
+
+// AuthorizePayment asks the payment provider to hold the order total. A
+// declined authorization marks the payment failed and may be retried.
+func (s *OrderStore) AuthorizePayment(ctx context.Context, id int, method string) (*Order, error) {
+	if !payment.Methods[method] {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPaymentMethod, method)
+	}
+
+	// Provider calls happen outside s.mutex; the order's payment lock keeps
+	// two payment operations on the same order from interleaving
+	defer s.paymentLocks.lock(id)()
+
+	order, exists := s.GetOrder(id)
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != StatusPending {
+		return nil, fmt.Errorf("%w: order is %s", ErrPaymentState, order.Status)
+	}
+	if order.PaymentStatus != PaymentStatusPending && order.PaymentStatus != PaymentStatusFailed {
+		return nil, fmt.Errorf("%w: payment is %s", ErrPaymentState, order.PaymentStatus)
+	}
+
+	result, err := s.payments.Authorize(ctx, payment.Request{
+		OrderID:  order.ID,
+		Amount:   order.Total.Amount,
+		Currency: order.Total.Currency,
+		Method:   method,
+	})
+	if errors.Is(err, payment.ErrDeclined) {
+		if _, commitErr := s.commitPayment(id, func(order *Order) *StatusChange {
+			order.PaymentStatus = PaymentStatusFailed
+			return nil
+		}); commitErr != nil {
+			return nil, commitErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.commitPayment(id, func(order *Order) *StatusChange {
+		order.PaymentStatus = PaymentStatusProcessing
+		order.Payment = &PaymentInfo{
+			ID:             result.PaymentID,
+			Method:         method,
+			Amount:         order.Total,
+			TransactionRef: result.TransactionRef,
+			AuthorizedAt:   time.Now().Format(time.RFC3339),
+		}
+		return nil
+	})
+}
+
+// CapturePayment takes an authorized payment and confirms the order on
+// behalf of actor
+func (s *OrderStore) CapturePayment(ctx context.Context, id int, actor string) (*Order, error) {
+	defer s.paymentLocks.lock(id)()
+
+	order, exists := s.GetOrder(id)
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != StatusPending {
+		return nil, fmt.Errorf("%w: order is %s", ErrPaymentState, order.Status)
+	}
+	if order.PaymentStatus != PaymentStatusProcessing || order.Payment == nil {
+		return nil, fmt.Errorf("%w: payment is %s", ErrPaymentState, order.PaymentStatus)
+	}
+
+	result, err := s.payments.Capture(ctx, order.Payment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.commitPayment(id, func(order *Order) *StatusChange {
+		order.PaymentStatus = PaymentStatusCompleted
+		order.Payment.TransactionRef = result.TransactionRef
+		order.Payment.CapturedAt = time.Now().Format(time.RFC3339)
+
+		// The order may have been cancelled while the provider was called;
+		// the payment is still recorded so that it can be refunded
+		if order.Status != StatusPending {
+			return nil
+		}
+		change := &StatusChange{
+			From:   order.Status,
+			To:     StatusConfirmed,
+			At:     order.Payment.CapturedAt,
+			Actor:  actor,
+			Reason: paymentCapturedReason,
+		}
+		order.Status = StatusConfirmed
+		return change
+	})
+}
+
+// RefundPayment returns a captured payment in full. Only cancelled or
+// delivered orders can be refunded.
+func (s *OrderStore) RefundPayment(ctx context.Context, id int) (*Order, error) {
+	defer s.paymentLocks.lock(id)()
+
+	order, exists := s.GetOrder(id)
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != StatusCancelled && order.Status != StatusDelivered {
+		return nil, fmt.Errorf("%w: order is %s", ErrPaymentState, order.Status)
+	}
+	if order.PaymentStatus != PaymentStatusCompleted || order.Payment == nil {
+		return nil, fmt.Errorf("%w: payment is %s", ErrPaymentState, order.PaymentStatus)
+	}
+	return s.refund(ctx, order)
+}
+
+// releasePayment is the cancel hook that gives back the payment of a
+// cancelled order. Captured payments are refunded; authorizations that were
+// never captured are marked cancelled and expire at the provider.
+func (s *OrderStore) releasePayment(ctx context.Context, order *Order) error {
+	defer s.paymentLocks.lock(order.ID)()
+
+	// A capture may have completed since order was read
+	order, exists := s.GetOrder(order.ID)
+	if !exists {
+		return ErrOrderNotFound
+	}
+	var err error
+	switch order.PaymentStatus {
+	case PaymentStatusCompleted:
+		_, err = s.refund(ctx, order)
+	case PaymentStatusProcessing:
+		_, err = s.commitPayment(order.ID, func(order *Order) *StatusChange {
+			order.PaymentStatus = PaymentStatusCancelled
+			return nil
+		})
+	}
+	return err
+}
+
+// refund returns the captured payment of order at the provider; the caller
+// holds the order's payment lock
+func (s *OrderStore) refund(ctx context.Context, order *Order) (*Order, error) {
+	result, err := s.payments.Refund(ctx, order.Payment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.commitPayment(order.ID, func(order *Order) *StatusChange {
+		order.PaymentStatus = PaymentStatusRefunded
+		order.Payment.TransactionRef = result.TransactionRef
+		order.Payment.RefundedAt = time.Now().Format(time.RFC3339)
+		return nil
+	})
+}
+
+// orderLocks holds a mutex per order. The zero value is ready to use, and
+// the mutex of an order is dropped once nobody holds or waits for it.
+type orderLocks struct {
+	mutex sync.Mutex
+	locks map[int]*orderLock
+}
+
+type orderLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock acquires the mutex of order id and returns the function releasing it
+func (l *orderLocks) lock(id int) func() {
+	l.mutex.Lock()
+	if l.locks == nil {
+		l.locks = make(map[int]*orderLock)
+	}
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = &orderLock{}
+		l.locks[id] = lock
+	}
+	lock.refs++
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(l.locks, id)
+		}
+	}
+}
+
+// commitPayment applies update to a copy of order id and commits it along
+// with the status change update returns, if any
+func (s *OrderStore) commitPayment(id int, update func(order *Order) *StatusChange) (*Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[id]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	updated := *order
+	if order.Payment != nil {
+		info := *order.Payment
+		updated.Payment = &info
+	}
+
+	change := update(&updated)
+	if err := s.commitRecord(walRecord{Op: opPutOrder, Order: &updated, Change: change}); err != nil {
+		return nil, err
+	}
+	if change != nil {
+		orderTransitionsCounter.WithLabelValues(change.From, change.To).Inc()
+	}
+
+	return &updated, nil
+}
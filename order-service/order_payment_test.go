@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/internal/payment"
+)
+
+// payOrder authorizes and captures the payment of order id, confirming it
+func payOrder(t *testing.T, store *OrderStore, id int) {
+	t.Helper()
+	if _, err := store.AuthorizePayment(context.Background(), id, payment.MethodCreditCard); err != nil {
+		t.Fatalf("AuthorizePayment failed: %v", err)
+	}
+	if _, err := store.CapturePayment(context.Background(), id, "tester"); err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+}
+
+func TestPaymentConfirmsOrder(t *testing.T) {
+	ctx := context.Background()
+	store := NewOrderStore()
+
+	if _, err := store.TransitionOrder(1, StatusConfirmed, "tester", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected an unpaid order not to be confirmed, got %v", err)
+	}
+	if _, err := store.CapturePayment(ctx, 1, "tester"); !errors.Is(err, ErrPaymentState) {
+		t.Errorf("Expected capture before authorization to fail, got %v", err)
+	}
+
+	order, err := store.AuthorizePayment(ctx, 1, payment.MethodDebitCard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PaymentStatus != PaymentStatusProcessing || order.Status != StatusPending {
+		t.Errorf("Expected an authorized, pending order, got %s and %s", order.PaymentStatus, order.Status)
+	}
+	if order.Payment == nil || order.Payment.ID == "" || order.Payment.Amount != order.Total {
+		t.Errorf("Expected the authorized amount to be recorded, got %+v", order.Payment)
+	}
+	if _, err := store.AuthorizePayment(ctx, 1, payment.MethodDebitCard); !errors.Is(err, ErrPaymentState) {
+		t.Errorf("Expected a second authorization to fail, got %v", err)
+	}
+
+	order, err = store.CapturePayment(ctx, 1, "checkout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PaymentStatus != PaymentStatusCompleted || order.Status != StatusConfirmed || order.Payment.CapturedAt == "" {
+		t.Errorf("Expected a paid, confirmed order, got %+v", order)
+	}
+	history, _ := store.GetOrderHistory(1)
+	if len(history) != 1 || history[0].To != StatusConfirmed || history[0].Actor != "checkout" || history[0].Reason != paymentCapturedReason {
+		t.Errorf("Expected the confirmation to be recorded, got %+v", history)
+	}
+
+	if _, err := store.TransitionOrder(1, StatusProcessing, "tester", ""); err != nil {
+		t.Errorf("Expected a confirmed order to move to processing, got %v", err)
+	}
+}
+
+func TestPaymentDeclined(t *testing.T) {
+	ctx := context.Background()
+	store := NewOrderStore()
+	fake := payment.NewFake()
+	fake.Limit = 1000
+	store.payments = fake
+
+	if _, err := store.AuthorizePayment(ctx, 1, payment.MethodCreditCard); !errors.Is(err, payment.ErrDeclined) {
+		t.Fatalf("Expected ErrDeclined, got %v", err)
+	}
+	if order, _ := store.GetOrder(1); order.PaymentStatus != PaymentStatusFailed || order.Payment != nil {
+		t.Errorf("Expected a failed payment, got %s and %+v", order.PaymentStatus, order.Payment)
+	}
+
+	// A failed payment may be retried
+	fake.Limit = 0
+	if _, err := store.AuthorizePayment(ctx, 1, payment.MethodCreditCard); err != nil {
+		t.Errorf("Expected a retried authorization to succeed, got %v", err)
+	}
+	if _, err := store.AuthorizePayment(ctx, 2, "cash"); !errors.Is(err, ErrInvalidPaymentMethod) {
+		t.Errorf("Expected ErrInvalidPaymentMethod, got %v", err)
+	}
+}
+
+func TestRefundPayment(t *testing.T) {
+	ctx := context.Background()
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+
+	if _, err := store.RefundPayment(ctx, 1); !errors.Is(err, ErrPaymentState) {
+		t.Errorf("Expected refunding an active order to fail, got %v", err)
+	}
+	// Cancelled orders are refunded by the cancel hook; delivered orders are
+	// refunded on request
+	for _, status := range []string{StatusProcessing, StatusShipped, StatusDelivered} {
+		var err error
+		if status == StatusShipped {
+			_, err = store.ShipOrder(1, "TRACK-123", "tester", "")
+		} else {
+			_, err = store.TransitionOrder(1, status, "tester", "")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	order, err := store.RefundPayment(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PaymentStatus != PaymentStatusRefunded || order.Payment.RefundedAt == "" {
+		t.Errorf("Expected a refunded payment, got %+v", order)
+	}
+	if _, err := store.RefundPayment(ctx, 1); !errors.Is(err, ErrPaymentState) {
+		t.Errorf("Expected a second refund to fail, got %v", err)
+	}
+	if _, err := store.RefundPayment(ctx, 999); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
+
+func TestPaymentSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store := openTestOrderStore(t, dir, 100)
+	payOrder(t, store, 2)
+	crash(t, store)
+
+	store = openTestOrderStore(t, dir, 100)
+	defer store.Close()
+	order, _ := store.GetOrder(2)
+	if order.Status != StatusConfirmed || order.PaymentStatus != PaymentStatusCompleted || order.Payment == nil {
+		t.Errorf("Expected the payment to survive a restart, got %+v", order)
+	}
+}
+
+func TestPaymentLocksArePerOrder(t *testing.T) {
+	var locks orderLocks
+	release := locks.lock(1)
+
+	acquired := make(chan struct{})
+	go func() {
+		locks.lock(2)()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected order 2 to lock while order 1 is held")
+	}
+
+	release()
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected released locks to be dropped, got %d", len(locks.locks))
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page size limits for order listings
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Fields orders can be sorted by
+const (
+	SortByID      = "id"
+	SortByCreated = "created"
+	SortByAmount  = "amount"
+)
+
+// Sort directions
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// ErrInvalidQuery is returned when list parameters are malformed
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ErrInvalidPageToken is returned when a page token is malformed or was issued
+// for a different query
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// OrderQuery selects, orders and pages a list of orders. Zero values leave a
+// filter unset. MinAmount and MaxAmount are minor units of Currency, which
+// they require, so that totals are only compared within one currency.
+type OrderQuery struct {
+	PageSize  int
+	PageToken string
+
+	UserID        int
+	Status        string
+	PaymentStatus string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Currency      string
+	MinAmount     int64
+	MaxAmount     int64
+
+	SortBy    string
+	SortOrder string
+}
+
+// OrderPage is one page of an order listing
+type OrderPage struct {
+	Orders        []*Order
+	NextPageToken string
+	TotalCount    int
+}
+
+// orderKey is the position of an order in a sorted listing. Orders compare
+// by Group, then Value, then ID. Totals are grouped by currency so that only
+// minor units of the same currency are ever compared.
+type orderKey struct {
+	Group string `json:"g,omitempty"`
+	Value int64  `json:"v"`
+}
+
+// pageCursor is the decoded form of a page token. It records the position of
+// the last order returned so that later pages are stable under inserts.
+type pageCursor struct {
+	Query string   `json:"q"`
+	ID    int      `json:"id"`
+	Key   orderKey `json:"k"`
+}
+
+// ParseOrderQuery reads an OrderQuery from URL query parameters
+func ParseOrderQuery(values url.Values) (OrderQuery, error) {
+	q := OrderQuery{
+		PageToken:     values.Get("page_token"),
+		Status:        values.Get("status"),
+		PaymentStatus: values.Get("payment_status"),
+		Currency:      values.Get("currency"),
+		SortBy:        values.Get("sort_by"),
+		SortOrder:     values.Get("sort_order"),
+	}
+
+	var err error
+	if q.PageSize, err = parseIntParam(values, "page_size"); err != nil {
+		return q, err
+	}
+	if q.UserID, err = parseIntParam(values, "user_id"); err != nil {
+		return q, err
+	}
+	if q.MinAmount, err = parseAmountParam(values, "min_amount", q.Currency); err != nil {
+		return q, err
+	}
+	if q.MaxAmount, err = parseAmountParam(values, "max_amount", q.Currency); err != nil {
+		return q, err
+	}
+	if q.CreatedAfter, err = parseTimeParam(values, "created_after"); err != nil {
+		return q, err
+	}
+	if q.CreatedBefore, err = parseTimeParam(values, "created_before"); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+func parseIntParam(values url.Values, name string) (int, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer", ErrInvalidQuery, name)
+	}
+	return n, nil
+}
+
+// parseAmountParam reads a decimal amount of currency in minor units
+func parseAmountParam(values url.Values, name, currency string) (int64, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	if currency == "" {
+		return 0, fmt.Errorf("%w: %s requires currency", ErrInvalidQuery, name)
+	}
+	amount, err := ParseMoney(value, currency)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %v", ErrInvalidQuery, name, err)
+	}
+	return amount.Amount, nil
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or Unix seconds
+func parseTimeParam(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be RFC 3339 or Unix seconds", ErrInvalidQuery, name)
+	}
+	return t, nil
+}
+
+// normalize applies defaults and validates the query
+func (q *OrderQuery) normalize() error {
+	switch {
+	case q.PageSize < 0:
+		return fmt.Errorf("%w: page_size must not be negative", ErrInvalidQuery)
+	case q.PageSize == 0:
+		q.PageSize = defaultPageSize
+	case q.PageSize > maxPageSize:
+		q.PageSize = maxPageSize
+	}
+
+	if q.UserID < 0 {
+		return fmt.Errorf("%w: user_id must be positive", ErrInvalidQuery)
+	}
+	if q.Status != "" {
+		if _, known := orderTransitions[q.Status]; !known {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidQuery, q.Status)
+		}
+	}
+	if q.PaymentStatus != "" && !paymentStatuses[q.PaymentStatus] {
+		return fmt.Errorf("%w: unknown payment_status %q", ErrInvalidQuery, q.PaymentStatus)
+	}
+	if q.Currency != "" {
+		currency, err := normalizeCurrency(q.Currency)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+		q.Currency = currency
+	} else if q.MinAmount != 0 || q.MaxAmount != 0 {
+		return fmt.Errorf("%w: min_amount and max_amount require currency", ErrInvalidQuery)
+	}
+	if q.MinAmount < 0 || q.MaxAmount < 0 {
+		return fmt.Errorf("%w: amounts must not be negative", ErrInvalidQuery)
+	}
+	if q.MaxAmount != 0 && q.MaxAmount < q.MinAmount {
+		return fmt.Errorf("%w: max_amount is below min_amount", ErrInvalidQuery)
+	}
+
+	q.SortBy = strings.ToLower(q.SortBy)
+	switch q.SortBy {
+	case "":
+		q.SortBy = SortByID
+	case SortByID, SortByCreated, SortByAmount:
+	default:
+		return fmt.Errorf("%w: unknown sort_by %q", ErrInvalidQuery, q.SortBy)
+	}
+
+	q.SortOrder = strings.ToLower(q.SortOrder)
+	switch q.SortOrder {
+	case "":
+		q.SortOrder = SortAsc
+	case SortAsc, SortDesc:
+	default:
+		return fmt.Errorf("%w: unknown sort_order %q", ErrInvalidQuery, q.SortOrder)
+	}
+
+	return nil
+}
+
+// matches reports whether order passes every filter in the query
+func (q *OrderQuery) matches(order *Order) bool {
+	if q.UserID != 0 && order.UserID != q.UserID {
+		return false
+	}
+	if q.Status != "" && order.Status != q.Status {
+		return false
+	}
+	if q.PaymentStatus != "" && order.PaymentStatus != q.PaymentStatus {
+		return false
+	}
+
+	created := createdKey(order)
+	if !q.CreatedAfter.IsZero() && created <= q.CreatedAfter.Unix() {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && created >= q.CreatedBefore.Unix() {
+		return false
+	}
+
+	if q.Currency != "" {
+		total := order.Total
+		if total.Currency != q.Currency || total.Amount < q.MinAmount || (q.MaxAmount != 0 && total.Amount > q.MaxAmount) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fingerprint identifies the filters and ordering a page token belongs to
+func (q *OrderQuery) fingerprint() string {
+	parts := []string{
+		strconv.Itoa(q.UserID),
+		q.Status,
+		q.PaymentStatus,
+		strconv.FormatInt(unixOrZero(q.CreatedAfter), 10),
+		strconv.FormatInt(unixOrZero(q.CreatedBefore), 10),
+		q.Currency,
+		strconv.FormatInt(q.MinAmount, 10),
+		strconv.FormatInt(q.MaxAmount, 10),
+		q.SortBy,
+		q.SortOrder,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// sortKey returns the key order is ordered by for field. Totals are ordered
+// by currency first.
+func sortKey(order *Order, field string) orderKey {
+	switch field {
+	case SortByCreated:
+		return orderKey{Value: createdKey(order)}
+	case SortByAmount:
+		return orderKey{Group: order.Total.Currency, Value: order.Total.Amount}
+	default:
+		return orderKey{}
+	}
+}
+
+// compareOrders orders by key and then by ID so that ties are stable
+func compareOrders(aKey orderKey, aID int, bKey orderKey, bID int) int {
+	switch {
+	case aKey.Group < bKey.Group:
+		return -1
+	case aKey.Group > bKey.Group:
+		return 1
+	case aKey.Value < bKey.Value:
+		return -1
+	case aKey.Value > bKey.Value:
+		return 1
+	case aID < bID:
+		return -1
+	case aID > bID:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// candidatesLocked returns the orders that may match q, taken from the most
+// selective index that applies. The caller must hold s.mutex.
+func (s *OrderStore) candidatesLocked(q *OrderQuery) []*Order {
+	var best []int
+	consider := func(ids []int) {
+		if best == nil || len(ids) < len(best) {
+			best = ids
+		}
+	}
+	fromSet := func(set idSet) []int {
+		ids := make([]int, 0, len(set))
+		for id := range set {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	if q.UserID != 0 {
+		consider(fromSet(s.index.byUser[q.UserID]))
+	}
+	if q.Status != "" {
+		consider(fromSet(s.index.byStatus[q.Status]))
+	}
+	if q.PaymentStatus != "" {
+		consider(fromSet(s.index.byPayment[q.PaymentStatus]))
+	}
+	if !q.CreatedAfter.IsZero() || !q.CreatedBefore.IsZero() {
+		min, max := int64(math.MinInt64), int64(math.MaxInt64)
+		if !q.CreatedAfter.IsZero() {
+			min = q.CreatedAfter.Unix()
+		}
+		if !q.CreatedBefore.IsZero() {
+			max = q.CreatedBefore.Unix()
+		}
+		consider(s.index.byCreated.between(min, max))
+	}
+	if q.Currency != "" {
+		max := int64(math.MaxInt64)
+		if q.MaxAmount != 0 {
+			max = q.MaxAmount
+		}
+		amounts := s.index.byAmount[q.Currency]
+		if amounts == nil {
+			amounts = &sortedIndex{}
+		}
+		consider(amounts.between(q.MinAmount, max))
+	}
+
+	if best == nil {
+		orders := make([]*Order, 0, len(s.orders))
+		for _, order := range s.orders {
+			orders = append(orders, order)
+		}
+		return orders
+	}
+
+	orders := make([]*Order, 0, len(best))
+	for _, id := range best {
+		if order, ok := s.orders[id]; ok {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// ListOrders returns the page of orders selected by q
+func (s *OrderStore) ListOrders(q OrderQuery) (*OrderPage, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	fingerprint := q.fingerprint()
+
+	var after *pageCursor
+	if q.PageToken != "" {
+		cursor, err := decodePageToken(q.PageToken)
+		if err != nil || cursor.Query != fingerprint {
+			return nil, ErrInvalidPageToken
+		}
+		after = cursor
+	}
+
+	s.mutex.RLock()
+	matched := make([]*Order, 0)
+	for _, order := range s.candidatesLocked(&q) {
+		if q.matches(order) {
+			matched = append(matched, order)
+		}
+	}
+	s.mutex.RUnlock()
+
+	// direction flips comparisons for descending order
+	direction := 1
+	if q.SortOrder == SortDesc {
+		direction = -1
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		return direction*compareOrders(sortKey(a, q.SortBy), a.ID, sortKey(b, q.SortBy), b.ID) < 0
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			order := matched[i]
+			return direction*compareOrders(sortKey(order, q.SortBy), order.ID, after.Key, after.ID) > 0
+		})
+	}
+	end := start + q.PageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	page := &OrderPage{
+		Orders:     append(make([]*Order, 0, end-start), matched[start:end]...),
+		TotalCount: len(matched),
+	}
+	if end < len(matched) {
+		last := matched[end-1]
+		page.NextPageToken = encodePageToken(&pageCursor{
+			Query: fingerprint,
+			ID:    last.ID,
+			Key:   sortKey(last, q.SortBy),
+		})
+	}
+
+	return page, nil
+}
+
+// encodePageToken turns a cursor into an opaque token
+func encodePageToken(cursor *pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken parses a token produced by encodePageToken
+func decodePageToken(token string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
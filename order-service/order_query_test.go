@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newQueryTestStore returns an empty store with orders created an hour apart
+func newQueryTestStore(t *testing.T) *OrderStore {
+	t.Helper()
+	store := newOrderStore()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixtures := []struct {
+		userID int
+		price  int64
+		status string
+	}{
+		{1, 1000, "pending"},
+		{2, 25000, "shipped"},
+		{1, 7500, "pending"},
+		{3, 7500, "delivered"},
+		{1, 50000, "cancelled"},
+	}
+	for i, f := range fixtures {
+		order, err := store.CreateOrder(f.userID, singleItem(fmt.Sprintf("Item %d", i+1), 1, usd(f.price)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated := *order
+		updated.Status = f.status
+		updated.Created = base.Add(time.Duration(i) * time.Hour).Format(time.RFC3339)
+		store.mutex.Lock()
+		err = store.commit(&updated)
+		store.mutex.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func orderIDs(orders []*Order) []int {
+	ids := make([]int, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
+
+func TestListOrdersFilters(t *testing.T) {
+	store := newQueryTestStore(t)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query OrderQuery
+		want  []int
+	}{
+		{"all", OrderQuery{}, []int{1, 2, 3, 4, 5}},
+		{"user", OrderQuery{UserID: 1}, []int{1, 3, 5}},
+		{"status", OrderQuery{Status: "pending"}, []int{1, 3}},
+		{"user and status", OrderQuery{UserID: 1, Status: "cancelled"}, []int{5}},
+		{"payment status", OrderQuery{PaymentStatus: PaymentStatusPending}, []int{1, 2, 3, 4, 5}},
+		{"no payments refunded", OrderQuery{PaymentStatus: PaymentStatusRefunded}, []int{}},
+		{"created after", OrderQuery{CreatedAfter: base.Add(2 * time.Hour)}, []int{4, 5}},
+		{"created before", OrderQuery{CreatedBefore: base.Add(time.Hour)}, []int{1}},
+		{"amount range", OrderQuery{Currency: "USD", MinAmount: 7500, MaxAmount: 25000}, []int{2, 3, 4}},
+		{"min amount", OrderQuery{Currency: "usd", MinAmount: 25001}, []int{5}},
+		{"other currency", OrderQuery{Currency: "JPY"}, []int{}},
+		{"sort by amount desc", OrderQuery{SortBy: SortByAmount, SortOrder: SortDesc}, []int{5, 2, 4, 3, 1}},
+		{"sort by created desc", OrderQuery{SortBy: SortByCreated, SortOrder: SortDesc, UserID: 1}, []int{5, 3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.ListOrders(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := orderIDs(page.Orders); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+			if page.TotalCount != len(tt.want) {
+				t.Errorf("Expected total count %d, got %d", len(tt.want), page.TotalCount)
+			}
+		})
+	}
+}
+
+func TestListOrdersPagination(t *testing.T) {
+	store := newQueryTestStore(t)
+
+	var seen []int
+	query := OrderQuery{PageSize: 2, SortBy: SortByAmount}
+	for {
+		page, err := store.ListOrders(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, orderIDs(page.Orders)...)
+		if page.NextPageToken == "" {
+			break
+		}
+		query.PageToken = page.NextPageToken
+
+		// A cheap order added between pages sorts before the cursor
+		if _, err := store.CreateOrder(9, singleItem("Sticker", 1, usd(100))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Orders 3 and 4 tie on amount and are ordered by ID
+	if fmt.Sprint(seen) != "[1 3 4 2 5]" {
+		t.Errorf("Expected [1 3 4 2 5], got %v", seen)
+	}
+}
+
+func TestListOrdersAmountsByCurrency(t *testing.T) {
+	store := newQueryTestStore(t)
+	// 1000 JPY is fewer minor units than most USD orders but must not be
+	// compared with them
+	yen, err := store.CreateOrder(1, singleItem("Snack", 1, Money{Amount: 1000, Currency: "JPY"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := store.ListOrders(OrderQuery{Currency: "JPY", MinAmount: 500, MaxAmount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orderIDs(page.Orders); fmt.Sprint(got) != fmt.Sprint([]int{yen.ID}) {
+		t.Errorf("Expected only the yen order, got %v", got)
+	}
+	page, err = store.ListOrders(OrderQuery{Currency: "USD", MaxAmount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orderIDs(page.Orders); fmt.Sprint(got) != "[1]" {
+		t.Errorf("Expected only the 10 USD order, got %v", got)
+	}
+
+	// Sorting by amount groups orders by currency
+	page, err = store.ListOrders(OrderQuery{SortBy: SortByAmount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orderIDs(page.Orders); fmt.Sprint(got) != fmt.Sprint([]int{yen.ID, 1, 3, 4, 2, 5}) {
+		t.Errorf("Expected the yen order before the USD orders, got %v", got)
+	}
+}
+
+func TestListOrdersRejectsBadInput(t *testing.T) {
+	store := newQueryTestStore(t)
+
+	page, err := store.ListOrders(OrderQuery{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query OrderQuery
+		want  error
+	}{
+		{"negative page size", OrderQuery{PageSize: -1}, ErrInvalidQuery},
+		{"unknown status", OrderQuery{Status: "lost"}, ErrInvalidQuery},
+		{"unknown payment status", OrderQuery{PaymentStatus: "owed"}, ErrInvalidQuery},
+		{"inverted amount range", OrderQuery{Currency: "USD", MinAmount: 10, MaxAmount: 5}, ErrInvalidQuery},
+		{"amount without currency", OrderQuery{MinAmount: 10}, ErrInvalidQuery},
+		{"unknown currency", OrderQuery{Currency: "XYZ"}, ErrInvalidQuery},
+		{"unknown sort", OrderQuery{SortBy: "product"}, ErrInvalidQuery},
+		{"garbage token", OrderQuery{PageToken: "%%%"}, ErrInvalidPageToken},
+		{"token from another query", OrderQuery{PageToken: page.NextPageToken, Status: "pending"}, ErrInvalidPageToken},
+	}
+	for _, tt := range tests {
+		if _, err := store.ListOrders(tt.query); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestParseOrderQuery(t *testing.T) {
+	values, _ := url.ParseQuery("user_id=3&status=shipped&payment_status=completed&currency=usd&min_amount=10.5&max_amount=99&created_after=2025-01-01T00:00:00Z&created_before=1767225600&sort_by=amount&sort_order=desc&page_size=5")
+	q, err := ParseOrderQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.UserID != 3 || q.Status != "shipped" || q.PaymentStatus != PaymentStatusCompleted || q.PageSize != 5 {
+		t.Errorf("Unexpected query: %+v", q)
+	}
+	if q.Currency != "usd" || q.MinAmount != 1050 || q.MaxAmount != 9900 || q.CreatedBefore.Unix() != 1767225600 {
+		t.Errorf("Unexpected ranges: %+v", q)
+	}
+
+	for _, bad := range []string{"user_id=abc", "currency=USD&min_amount=-1", "currency=USD&max_amount=lots", "min_amount=10", "currency=JPY&min_amount=1.5", "created_after=today", "page_size=big"} {
+		values, _ := url.ParseQuery(bad)
+		if _, err := ParseOrderQuery(values); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Shipping methods, mirroring ShippingMethod in order.proto
+const (
+	ShippingStandard  = "standard"
+	ShippingExpress   = "express"
+	ShippingOvernight = "overnight"
+	ShippingPickup    = "pickup"
+)
+
+// ErrInvalidShipping is returned when shipping details are missing or malformed
+var ErrInvalidShipping = errors.New("invalid shipping details")
+
+// trackingNumberPattern accepts carrier tracking numbers such as 1Z999AA10123456784
+var trackingNumberPattern = regexp.MustCompile(`^[A-Za-z0-9-]{4,64}$`)
+
+// ShippingInfo is where and how an order is delivered. Cost and
+// EstimatedDelivery are computed by the server from the rate table.
+type ShippingInfo struct {
+	Address           string `json:"address,omitempty"`
+	City              string `json:"city,omitempty"`
+	PostalCode        string `json:"postal_code,omitempty"`
+	Country           string `json:"country,omitempty"`
+	Method            string `json:"method"`
+	Cost              Money  `json:"cost"`
+	TrackingNumber    string `json:"tracking_number,omitempty"`
+	EstimatedDelivery string `json:"estimated_delivery,omitempty"`
+}
+
+// ShippingRate prices a shipping method in one currency. Base covers the
+// first unit and PerItem each further unit.
+type ShippingRate struct {
+	Base    Money `json:"base"`
+	PerItem Money `json:"per_item"`
+	Days    int   `json:"days"`
+}
+
+// ShippingRates maps each shipping method to its rates, at most one per currency
+type ShippingRates map[string][]ShippingRate
+
+// defaultShippingRates is used when SHIPPING_RATES is not set
+var defaultShippingRates = ShippingRates{
+	ShippingStandard: {
+		{Base: Money{499, "USD"}, PerItem: Money{50, "USD"}, Days: 5},
+		{Base: Money{499, "EUR"}, PerItem: Money{50, "EUR"}, Days: 5},
+	},
+	ShippingExpress: {
+		{Base: Money{1499, "USD"}, PerItem: Money{100, "USD"}, Days: 2},
+		{Base: Money{1499, "EUR"}, PerItem: Money{100, "EUR"}, Days: 2},
+	},
+	ShippingOvernight: {
+		{Base: Money{2999, "USD"}, PerItem: Money{200, "USD"}, Days: 1},
+		{Base: Money{2999, "EUR"}, PerItem: Money{200, "EUR"}, Days: 1},
+	},
+	ShippingPickup: {
+		{Base: Money{0, "USD"}, PerItem: Money{0, "USD"}},
+		{Base: Money{0, "EUR"}, PerItem: Money{0, "EUR"}},
+	},
+}
+
+// shippingRatesFromEnv reads the rate table from the JSON in SHIPPING_RATES,
+// e.g. {"standard":[{"base":{"amount":"4.99","currency":"USD"},"days":5}]}
+func shippingRatesFromEnv() (ShippingRates, error) {
+	value := os.Getenv("SHIPPING_RATES")
+	if value == "" {
+		return defaultShippingRates, nil
+	}
+
+	var rates ShippingRates
+	if err := json.Unmarshal([]byte(value), &rates); err != nil {
+		return nil, fmt.Errorf("invalid SHIPPING_RATES: %w", err)
+	}
+	if err := rates.validate(); err != nil {
+		return nil, fmt.Errorf("invalid SHIPPING_RATES: %w", err)
+	}
+	return rates, nil
+}
+
+// validate checks that every rate is in a single supported currency
+func (r ShippingRates) validate() error {
+	for method, rates := range r {
+		seen := make(map[string]bool)
+		for i := range rates {
+			rate := &rates[i]
+			if rate.PerItem.Currency == "" {
+				rate.PerItem.Currency = rate.Base.Currency
+			}
+			switch {
+			case rate.Base.Currency != rate.PerItem.Currency:
+				return fmt.Errorf("%s: %w: base in %s, per_item in %s", method, ErrCurrencyMismatch, rate.Base.Currency, rate.PerItem.Currency)
+			case seen[rate.Base.Currency]:
+				return fmt.Errorf("%s: more than one rate in %s", method, rate.Base.Currency)
+			case rate.Days < 0:
+				return fmt.Errorf("%s: days must not be negative", method)
+			}
+			seen[rate.Base.Currency] = true
+		}
+	}
+	return nil
+}
+
+// quote returns the cost and delivery time of shipping units items by method
+// in currency
+func (r ShippingRates) quote(method, currency string, units int) (Money, int, error) {
+	rates, ok := r[method]
+	if !ok {
+		return Money{}, 0, fmt.Errorf("%w: unknown method %q", ErrInvalidShipping, method)
+	}
+	for _, rate := range rates {
+		if rate.Base.Currency != currency {
+			continue
+		}
+		extra, err := rate.PerItem.Mul(units - 1)
+		if err != nil {
+			return Money{}, 0, err
+		}
+		cost, err := rate.Base.Add(extra)
+		if err != nil {
+			return Money{}, 0, err
+		}
+		return cost, rate.Days, nil
+	}
+	return Money{}, 0, fmt.Errorf("%w: %s shipping is not available in %s", ErrInvalidShipping, method, currency)
+}
+
+// priceShipping validates the requested shipping details of an order whose
+// items total itemsTotal over units units, and returns them with the cost and
+// estimated delivery filled in
+func (r ShippingRates) priceShipping(requested ShippingInfo, itemsTotal Money, units int, now time.Time) (*ShippingInfo, error) {
+	shipping := ShippingInfo{
+		Address:    strings.TrimSpace(requested.Address),
+		City:       strings.TrimSpace(requested.City),
+		PostalCode: strings.TrimSpace(requested.PostalCode),
+		Country:    strings.ToUpper(strings.TrimSpace(requested.Country)),
+		Method:     strings.ToLower(strings.TrimSpace(requested.Method)),
+	}
+	if shipping.Method == "" {
+		shipping.Method = ShippingStandard
+	}
+	if shipping.Method != ShippingPickup && (shipping.Address == "" || shipping.City == "" || shipping.Country == "") {
+		return nil, fmt.Errorf("%w: address, city and country are required", ErrInvalidShipping)
+	}
+
+	cost, days, err := r.quote(shipping.Method, itemsTotal.Currency, units)
+	if err != nil {
+		return nil, err
+	}
+	shipping.Cost = cost
+	shipping.EstimatedDelivery = now.AddDate(0, 0, days).Format(time.RFC3339)
+	return &shipping, nil
+}
+
+// totalUnits returns the number of units across all items
+func totalUnits(items []OrderItem) int {
+	units := 0
+	for _, item := range items {
+		units += item.Quantity
+	}
+	return units
+}
+
+// validateTrackingNumber checks the format of a carrier tracking number
+func validateTrackingNumber(tracking string) error {
+	if !trackingNumberPattern.MatchString(tracking) {
+		return fmt.Errorf("%w: malformed tracking number %q", ErrInvalidShipping, tracking)
+	}
+	return nil
+}
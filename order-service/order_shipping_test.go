@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShippingQuote(t *testing.T) {
+	tests := []struct {
+		method   string
+		currency string
+		units    int
+		want     int64
+		days     int
+	}{
+		{ShippingStandard, "USD", 1, 499, 5},
+		{ShippingStandard, "USD", 3, 599, 5},
+		{ShippingExpress, "EUR", 2, 1599, 2},
+		{ShippingOvernight, "USD", 1, 2999, 1},
+		{ShippingPickup, "USD", 10, 0, 0},
+	}
+	for _, tt := range tests {
+		cost, days, err := defaultShippingRates.quote(tt.method, tt.currency, tt.units)
+		if err != nil {
+			t.Errorf("quote(%s, %s, %d) failed: %v", tt.method, tt.currency, tt.units, err)
+			continue
+		}
+		if cost != (Money{tt.want, tt.currency}) || days != tt.days {
+			t.Errorf("quote(%s, %s, %d) = %v in %d days, want %d in %d days", tt.method, tt.currency, tt.units, cost, days, tt.want, tt.days)
+		}
+	}
+
+	if _, _, err := defaultShippingRates.quote("teleport", "USD", 1); !errors.Is(err, ErrInvalidShipping) {
+		t.Errorf("Expected ErrInvalidShipping for an unknown method, got %v", err)
+	}
+	if _, _, err := defaultShippingRates.quote(ShippingStandard, "JPY", 1); !errors.Is(err, ErrInvalidShipping) {
+		t.Errorf("Expected ErrInvalidShipping for a currency without rates, got %v", err)
+	}
+}
+
+func TestPriceShipping(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	shipping, err := defaultShippingRates.priceShipping(ShippingInfo{
+		Address: " 1 Main St ",
+		City:    "Springfield",
+		Country: "us",
+		Method:  "Express",
+		Cost:    usd(1),
+	}, usd(2000), 2, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shipping.Address != "1 Main St" || shipping.Country != "US" || shipping.Method != ShippingExpress {
+		t.Errorf("Expected normalized shipping details, got %+v", shipping)
+	}
+	if shipping.Cost != usd(1599) || shipping.EstimatedDelivery != "2024-03-03T12:00:00Z" {
+		t.Errorf("Expected the cost and delivery from the rate table, got %v and %s", shipping.Cost, shipping.EstimatedDelivery)
+	}
+
+	shipping, err = defaultShippingRates.priceShipping(ShippingInfo{Address: "1 Main St", City: "Springfield", Country: "US"}, usd(2000), 1, now)
+	if err != nil || shipping.Method != ShippingStandard {
+		t.Errorf("Expected standard shipping by default, got %+v, %v", shipping, err)
+	}
+	if _, err := defaultShippingRates.priceShipping(ShippingInfo{Method: ShippingPickup}, usd(2000), 1, now); err != nil {
+		t.Errorf("Expected pickup to need no address, got %v", err)
+	}
+	if _, err := defaultShippingRates.priceShipping(ShippingInfo{City: "Springfield", Country: "US"}, usd(2000), 1, now); !errors.Is(err, ErrInvalidShipping) {
+		t.Errorf("Expected ErrInvalidShipping without an address, got %v", err)
+	}
+}
+
+func TestShippingRatesFromEnv(t *testing.T) {
+	t.Setenv("SHIPPING_RATES", "")
+	rates, err := shippingRatesFromEnv()
+	if err != nil || len(rates) != len(defaultShippingRates) {
+		t.Errorf("Expected the default rates, got %v, %v", rates, err)
+	}
+
+	t.Setenv("SHIPPING_RATES", `{"standard":[{"base":{"amount":"500","currency":"JPY"},"per_item":{"amount":"100","currency":"JPY"},"days":3}],"freight":[{"base":{"amount":"99.00","currency":"USD"},"days":10}]}`)
+	rates, err = shippingRatesFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, days, err := rates.quote(ShippingStandard, "JPY", 3); err != nil || cost != (Money{700, "JPY"}) || days != 3 {
+		t.Errorf("Expected 700 JPY in 3 days, got %v in %d days, %v", cost, days, err)
+	}
+	if cost, _, err := rates.quote("freight", "USD", 4); err != nil || cost != usd(9900) {
+		t.Errorf("Expected per_item to default to zero in the base currency, got %v, %v", cost, err)
+	}
+
+	for _, value := range []string{
+		`not json`,
+		`{"standard":[{"base":{"amount":"1.00","currency":"USD"},"per_item":{"amount":"1.00","currency":"EUR"}}]}`,
+		`{"standard":[{"base":{"amount":"1.00","currency":"USD"}},{"base":{"amount":"2.00","currency":"USD"}}]}`,
+		`{"standard":[{"base":{"amount":"1.00","currency":"USD"},"days":-1}]}`,
+	} {
+		t.Setenv("SHIPPING_RATES", value)
+		if _, err := shippingRatesFromEnv(); err == nil {
+			t.Errorf("Expected SHIPPING_RATES %s to be rejected", value)
+		}
+	}
+}
+
+func TestShipOrderRequiresTrackingNumber(t *testing.T) {
+	store := NewOrderStore()
+	payOrder(t, store, 1)
+	if _, err := store.TransitionOrder(1, StatusProcessing, "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.TransitionOrder(1, StatusShipped, "tester", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected shipping without a tracking number to fail, got %v", err)
+	}
+	for _, tracking := range []string{"", "abc", "has spaces 123"} {
+		if _, err := store.ShipOrder(1, tracking, "tester", ""); !errors.Is(err, ErrInvalidShipping) {
+			t.Errorf("Expected tracking number %q to be rejected, got %v", tracking, err)
+		}
+	}
+	if order, _ := store.GetOrder(1); order.Status != StatusProcessing || order.Shipping != nil {
+		t.Errorf("Expected the order to be unchanged, got %+v", order)
+	}
+
+	order, err := store.ShipOrder(1, "1Z999AA10123456784", "warehouse", "handed to carrier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != StatusShipped || order.Shipping.TrackingNumber != "1Z999AA10123456784" {
+		t.Errorf("Expected a shipped order with its tracking number, got %+v", order)
+	}
+	if order.Shipping.Cost != (Money{Currency: order.Currency}) {
+		t.Errorf("Expected no shipping cost for an order created without shipping, got %v", order.Shipping.Cost)
+	}
+}
+
+func TestCreateOrderWithShipping(t *testing.T) {
+	store := NewOrderStore()
+
+	order, err := store.CreateOrderWithShipping(1, singleItem("Lamp", 2, usd(2500)), &ShippingInfo{
+		Address: "1 Main St",
+		City:    "Springfield",
+		Country: "US",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Shipping == nil || order.Shipping.Cost != usd(549) || order.Shipping.EstimatedDelivery == "" {
+		t.Errorf("Expected standard shipping for two units, got %+v", order.Shipping)
+	}
+	if order.Total != usd(5549) {
+		t.Errorf("Expected the shipping cost in the total, got %v", order.Total)
+	}
+
+	if _, err := store.CreateOrderWithShipping(1, singleItem("Lamp", 1, Money{1000, "JPY"}), &ShippingInfo{Method: ShippingPickup}); !errors.Is(err, ErrInvalidShipping) {
+		t.Errorf("Expected ErrInvalidShipping for a currency without rates, got %v", err)
+	}
+}
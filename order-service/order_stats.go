@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Bucket sizes of an order stats report
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// maxStatsBuckets caps the number of buckets in one report
+const maxStatsBuckets = 1000
+
+// defaultStatsRange is covered by requests that give no start
+const defaultStatsRange = 30 * 24 * time.Hour
+
+// OrderStats summarises the orders created within [Start, End). Statuses are
+// the current status of each order. Revenue only counts orders whose payment
+// was captured and not refunded, and cancelled orders bring no revenue.
+type OrderStats struct {
+	Start           string `json:"start,omitempty"`
+	End             string `json:"end,omitempty"`
+	TotalOrders     int    `json:"total_orders"`
+	PendingOrders   int    `json:"pending_orders"`
+	CompletedOrders int    `json:"completed_orders"`
+	CancelledOrders int    `json:"cancelled_orders"`
+
+	// Revenue and AverageOrderValue hold one amount per currency, ordered by
+	// code, as amounts in different currencies cannot be added up
+	Revenue           []Money `json:"revenue"`
+	AverageOrderValue []Money `json:"average_order_value"`
+}
+
+// OrderStatsReport is the summary of a time range and its breakdown into
+// buckets, oldest first
+type OrderStatsReport struct {
+	Bucket  string       `json:"bucket,omitempty"`
+	Summary OrderStats   `json:"summary"`
+	Buckets []OrderStats `json:"buckets,omitempty"`
+}
+
+// OrderStatsQuery selects the orders of a report. A zero Start or End leaves
+// that side of the range open; Bucket requires both and may be empty for a
+// summary only.
+type OrderStatsQuery struct {
+	Start  time.Time
+	End    time.Time
+	Bucket string
+}
+
+// ParseOrderStatsQuery reads start, end and bucket from URL query values.
+// The range defaults to the 30 days up to now, in daily buckets.
+func ParseOrderStatsQuery(values url.Values, now time.Time) (OrderStatsQuery, error) {
+	q := OrderStatsQuery{Bucket: values.Get("bucket")}
+
+	var err error
+	if q.Start, err = parseTimeParam(values, "start"); err != nil {
+		return q, err
+	}
+	if q.End, err = parseTimeParam(values, "end"); err != nil {
+		return q, err
+	}
+	if q.End.IsZero() {
+		q.End = now
+	}
+	if q.Start.IsZero() {
+		q.Start = q.End.Add(-defaultStatsRange)
+	}
+	if q.Bucket == "" {
+		q.Bucket = BucketDay
+	}
+	return q, nil
+}
+
+// buckets validates the query and returns the start of each bucket followed
+// by the end of the range
+func (q *OrderStatsQuery) buckets() ([]time.Time, error) {
+	if !q.Start.IsZero() && !q.End.IsZero() && !q.Start.Before(q.End) {
+		return nil, fmt.Errorf("%w: start must be before end", ErrInvalidQuery)
+	}
+	if q.Bucket == "" {
+		return nil, nil
+	}
+	if _, ok := nextBucket(time.Time{}, q.Bucket); !ok {
+		return nil, fmt.Errorf("%w: bucket must be hour, day, week or month", ErrInvalidQuery)
+	}
+	if q.Start.IsZero() || q.End.IsZero() {
+		return nil, fmt.Errorf("%w: buckets require start and end", ErrInvalidQuery)
+	}
+
+	bounds := []time.Time{q.Start.UTC()}
+	for {
+		next, _ := nextBucket(bucketStart(bounds[len(bounds)-1], q.Bucket), q.Bucket)
+		if !next.Before(q.End) {
+			break
+		}
+		if len(bounds) == maxStatsBuckets {
+			return nil, fmt.Errorf("%w: at most %d buckets are allowed", ErrInvalidQuery, maxStatsBuckets)
+		}
+		bounds = append(bounds, next)
+	}
+	return append(bounds, q.End.UTC()), nil
+}
+
+// bucketStart truncates t to the start of its bucket in UTC. Weeks start on
+// Monday.
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case BucketMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+// nextBucket returns the start of the bucket after the one starting at start,
+// and false for unknown bucket sizes
+func nextBucket(start time.Time, bucket string) (time.Time, bool) {
+	switch bucket {
+	case BucketHour:
+		return start.Add(time.Hour), true
+	case BucketDay:
+		return start.AddDate(0, 0, 1), true
+	case BucketWeek:
+		return start.AddDate(0, 0, 7), true
+	case BucketMonth:
+		return start.AddDate(0, 1, 0), true
+	}
+	return time.Time{}, false
+}
+
+// statsAccumulator adds orders up into an OrderStats
+type statsAccumulator struct {
+	stats    OrderStats
+	revenue  map[string]Money
+	billable map[string]int
+}
+
+func newStatsAccumulator(start, end time.Time) *statsAccumulator {
+	acc := &statsAccumulator{revenue: make(map[string]Money), billable: make(map[string]int)}
+	if !start.IsZero() {
+		acc.stats.Start = start.UTC().Format(time.RFC3339)
+	}
+	if !end.IsZero() {
+		acc.stats.End = end.UTC().Format(time.RFC3339)
+	}
+	return acc
+}
+
+func (a *statsAccumulator) add(order *Order) error {
+	a.stats.TotalOrders++
+	switch order.Status {
+	case StatusPending:
+		a.stats.PendingOrders++
+	case StatusDelivered:
+		a.stats.CompletedOrders++
+	case StatusCancelled:
+		a.stats.CancelledOrders++
+		return nil
+	}
+	if order.PaymentStatus != PaymentStatusCompleted {
+		return nil
+	}
+
+	revenue, ok := a.revenue[order.Total.Currency]
+	if !ok {
+		revenue = Money{Currency: order.Total.Currency}
+	}
+	revenue, err := revenue.Add(order.Total)
+	if err != nil {
+		return err
+	}
+	a.revenue[order.Total.Currency] = revenue
+	a.billable[order.Total.Currency]++
+	return nil
+}
+
+// result returns the stats with the per-currency amounts filled in. Averages
+// are rounded half up to the minor unit.
+func (a *statsAccumulator) result() OrderStats {
+	currencies := make([]string, 0, len(a.revenue))
+	for currency := range a.revenue {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	stats := a.stats
+	stats.Revenue = make([]Money, 0, len(currencies))
+	stats.AverageOrderValue = make([]Money, 0, len(currencies))
+	for _, currency := range currencies {
+		revenue, count := a.revenue[currency], int64(a.billable[currency])
+		stats.Revenue = append(stats.Revenue, revenue)
+		average := revenue.Amount / count
+		if revenue.Amount%count*2 >= count {
+			average++
+		}
+		stats.AverageOrderValue = append(stats.AverageOrderValue, Money{Amount: average, Currency: currency})
+	}
+	return stats
+}
+
+// OrderStats reports on the orders created within the range of q
+func (s *OrderStore) OrderStats(q OrderStatsQuery) (*OrderStatsReport, error) {
+	bounds, err := q.buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	min, max := int64(math.MinInt64), int64(math.MaxInt64)
+	if !q.Start.IsZero() {
+		min = q.Start.Unix()
+	}
+	if !q.End.IsZero() {
+		max = q.End.Unix()
+	}
+
+	summary := newStatsAccumulator(q.Start, q.End)
+	buckets := make([]*statsAccumulator, 0, len(bounds))
+	for i := 0; i+1 < len(bounds); i++ {
+		buckets = append(buckets, newStatsAccumulator(bounds[i], bounds[i+1]))
+	}
+
+	for _, id := range s.index.byCreated.between(min, max) {
+		order := s.orders[id]
+		created := time.Unix(createdKey(order), 0)
+		if created.Before(q.Start) || (!q.End.IsZero() && !created.Before(q.End)) {
+			continue
+		}
+		if err := summary.add(order); err != nil {
+			return nil, fmt.Errorf("order %d: %w", id, err)
+		}
+		if len(buckets) > 0 {
+			i := sort.Search(len(bounds), func(i int) bool { return bounds[i].After(created) }) - 1
+			if err := buckets[i].add(order); err != nil {
+				return nil, fmt.Errorf("order %d: %w", id, err)
+			}
+		}
+	}
+
+	report := &OrderStatsReport{Bucket: q.Bucket, Summary: summary.result()}
+	for _, bucket := range buckets {
+		report.Buckets = append(report.Buckets, bucket.result())
+	}
+	return report, nil
+}
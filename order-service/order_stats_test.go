@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"order-service/internal/payment"
+)
+
+// setPaymentStatus records a payment status on orders ids without going
+// through the payment provider
+func setPaymentStatus(t *testing.T, store *OrderStore, status string, ids ...int) {
+	t.Helper()
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, id := range ids {
+		updated := *store.orders[id]
+		updated.PaymentStatus = status
+		if err := store.commit(&updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOrderStats(t *testing.T) {
+	store := newQueryTestStore(t)
+	setPaymentStatus(t, store, PaymentStatusCompleted, 2, 4)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	report, err := store.OrderStats(OrderStatsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := OrderStats{
+		TotalOrders:       5,
+		PendingOrders:     2,
+		CompletedOrders:   1,
+		CancelledOrders:   1,
+		Revenue:           []Money{usd(32500)},
+		AverageOrderValue: []Money{usd(16250)},
+	}
+	if !reflect.DeepEqual(report.Summary, want) || report.Buckets != nil {
+		t.Errorf("Expected summary %+v, got %+v", want, report)
+	}
+
+	// Two-hour buckets over [01:00, 04:00) leave out the first and last order
+	report, err = store.OrderStats(OrderStatsQuery{Start: base.Add(time.Hour), End: base.Add(4 * time.Hour), Bucket: BucketHour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary.TotalOrders != 3 || len(report.Buckets) != 3 {
+		t.Fatalf("Expected 3 orders in 3 buckets, got %+v", report)
+	}
+	for i, bucket := range report.Buckets {
+		if bucket.TotalOrders != 1 || bucket.Start != base.Add(time.Duration(i+1)*time.Hour).Format(time.RFC3339) {
+			t.Errorf("Unexpected bucket %d: %+v", i, bucket)
+		}
+	}
+}
+
+func TestOrderStatsPerCurrency(t *testing.T) {
+	store := newOrderStore()
+	for _, price := range []Money{usd(1000), usd(2001), {Amount: 500, Currency: "EUR"}, {Amount: 1200, Currency: "JPY"}} {
+		order, err := store.CreateOrder(1, singleItem("Item", 1, price))
+		if err != nil {
+			t.Fatal(err)
+		}
+		setPaymentStatus(t, store, PaymentStatusCompleted, order.ID)
+	}
+
+	report, err := store.OrderStats(OrderStatsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRevenue := []Money{{500, "EUR"}, {1200, "JPY"}, usd(3001)}
+	wantAverage := []Money{{500, "EUR"}, {1200, "JPY"}, usd(1501)}
+	if !reflect.DeepEqual(report.Summary.Revenue, wantRevenue) || !reflect.DeepEqual(report.Summary.AverageOrderValue, wantAverage) {
+		t.Errorf("Expected revenue %v and averages %v, got %v and %v", wantRevenue, wantAverage, report.Summary.Revenue, report.Summary.AverageOrderValue)
+	}
+}
+
+func TestOrderStatsCountsCapturedPayments(t *testing.T) {
+	ctx := context.Background()
+	store := newOrderStore()
+	var ids []int
+	for _, price := range []int64{1000, 2000, 4000} {
+		order, err := store.CreateOrder(1, singleItem("Item", 1, usd(price)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, order.ID)
+	}
+	paid, refunded, unpaid := ids[0], ids[1], ids[2]
+
+	payOrder(t, store, paid)
+	payOrder(t, store, refunded)
+	if err := store.UpdateOrderStatus(refunded, StatusProcessing); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ShipOrder(refunded, "TRACK1", "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateOrderStatus(refunded, StatusDelivered); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RefundPayment(ctx, refunded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AuthorizePayment(ctx, unpaid, payment.MethodCreditCard); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.OrderStats(OrderStatsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := report.Summary; s.TotalOrders != 3 || !reflect.DeepEqual(s.Revenue, []Money{usd(1000)}) || !reflect.DeepEqual(s.AverageOrderValue, []Money{usd(1000)}) {
+		t.Errorf("Expected revenue from the captured order only, got %+v", s)
+	}
+}
+
+func TestOrderStatsBuckets(t *testing.T) {
+	tests := []struct {
+		bucket string
+		start  time.Time
+		end    time.Time
+		want   []string
+	}{
+		{BucketDay, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2025, 1, 3, 6, 0, 0, 0, time.UTC),
+			[]string{"2025-01-01T12:00:00Z", "2025-01-02T00:00:00Z", "2025-01-03T00:00:00Z", "2025-01-03T06:00:00Z"}},
+		{BucketWeek, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC),
+			[]string{"2025-01-01T00:00:00Z", "2025-01-06T00:00:00Z", "2025-01-13T00:00:00Z", "2025-01-14T00:00:00Z"}},
+		{BucketMonth, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"2025-01-31T00:00:00Z", "2025-02-01T00:00:00Z", "2025-03-01T00:00:00Z"}},
+	}
+	for _, tt := range tests {
+		q := OrderStatsQuery{Start: tt.start, End: tt.end, Bucket: tt.bucket}
+		bounds, err := q.buckets()
+		if err != nil {
+			t.Errorf("%s: %v", tt.bucket, err)
+			continue
+		}
+		got := make([]string, 0, len(bounds))
+		for _, bound := range bounds {
+			got = append(got, bound.Format(time.RFC3339))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected bounds %v, got %v", tt.bucket, tt.want, got)
+		}
+	}
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, q := range []OrderStatsQuery{
+		{Start: base, End: base, Bucket: BucketDay},
+		{Start: base, End: base.AddDate(0, 0, 1), Bucket: "year"},
+		{End: base, Bucket: BucketDay},
+		{Start: base, End: base.AddDate(1, 0, 0), Bucket: BucketHour},
+	} {
+		if _, err := q.buckets(); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected ErrInvalidQuery for %+v, got %v", q, err)
+		}
+	}
+}
+
+func TestParseOrderStatsQuery(t *testing.T) {
+	now := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	q, err := ParseOrderStatsQuery(url.Values{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.End.Equal(now) || !q.Start.Equal(now.Add(-defaultStatsRange)) || q.Bucket != BucketDay {
+		t.Errorf("Expected the last 30 days by day, got %+v", q)
+	}
+
+	q, err = ParseOrderStatsQuery(url.Values{"start": {"2025-01-01T00:00:00Z"}, "end": {"1735776000"}, "bucket": {"hour"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Start.Unix() != 1735689600 || q.End.Unix() != 1735776000 || q.Bucket != BucketHour {
+		t.Errorf("Unexpected query %+v", q)
+	}
+
+	if _, err := ParseOrderStatsQuery(url.Values{"start": {"yesterday"}}, now); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for a malformed start, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Order statuses
+const (
+	StatusPending    = "pending"
+	StatusConfirmed  = "confirmed"
+	StatusProcessing = "processing"
+	StatusShipped    = "shipped"
+	StatusDelivered  = "delivered"
+	StatusCancelled  = "cancelled"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+// Orders can only be cancelled before they ship, and are only confirmed once
+// their payment has been captured.
+var orderTransitions = map[string][]string{
+	StatusPending:    {StatusConfirmed, StatusCancelled},
+	StatusConfirmed:  {StatusProcessing, StatusCancelled},
+	StatusProcessing: {StatusShipped, StatusCancelled},
+	StatusShipped:    {StatusDelivered},
+	StatusDelivered:  {},
+	StatusCancelled:  {},
+}
+
+// ErrInvalidTransition is returned when an order cannot move to the requested status
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// Actors recorded when a change has no identified caller
+const (
+	systemActor    = "system"
+	anonymousActor = "anonymous"
+)
+
+// actorHeader names the caller responsible for a change in HTTP requests
+const actorHeader = "X-Actor"
+
+// StatusChange records a single status transition of an order
+type StatusChange struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	At     string `json:"at"`
+	Actor  string `json:"actor"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// canTransition reports whether an order may move from one status to another
+func canTransition(from, to string) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionOrder moves an order to status on behalf of actor and records the
+// change in the order's history. Cancellations made this way have the reason
+// code "other" and run the cancel hooks.
+func (s *OrderStore) TransitionOrder(id int, status, actor, reason string) (*Order, error) {
+	order, err := s.transitionOrder(id, status, actor, reason, nil)
+	if err != nil || status != StatusCancelled {
+		return order, err
+	}
+	return s.runCancelHooks(context.Background(), order), nil
+}
+
+// ShipOrder records the tracking number of an order and moves it to shipped
+// in a single change
+func (s *OrderStore) ShipOrder(id int, tracking, actor, reason string) (*Order, error) {
+	tracking = strings.TrimSpace(tracking)
+	if err := validateTrackingNumber(tracking); err != nil {
+		return nil, err
+	}
+	return s.transitionOrder(id, StatusShipped, actor, reason, func(order *Order) {
+		// Orders created without shipping details only gain a tracking number
+		shipping := ShippingInfo{Cost: Money{Currency: order.Currency}}
+		if order.Shipping != nil {
+			shipping = *order.Shipping
+		}
+		shipping.TrackingNumber = tracking
+		order.Shipping = &shipping
+	})
+}
+
+// transitionOrder moves an order to status, applying update to the new state
+// of the order first when it is not nil
+func (s *OrderStore) transitionOrder(id int, status, actor, reason string, update func(order *Order)) (*Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[id]
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	if !canTransition(order.Status, status) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrInvalidTransition, order.Status, status)
+	}
+	if status == StatusConfirmed && order.PaymentStatus != PaymentStatusCompleted {
+		return nil, fmt.Errorf("%w to %s: payment is %s", ErrInvalidTransition, status, order.PaymentStatus)
+	}
+
+	updated := *order
+	if update != nil {
+		update(&updated)
+	}
+	if status == StatusShipped && (updated.Shipping == nil || updated.Shipping.TrackingNumber == "") {
+		return nil, fmt.Errorf("%w to %s: a tracking number is required", ErrInvalidTransition, status)
+	}
+
+	change := &StatusChange{
+		From:   order.Status,
+		To:     status,
+		At:     time.Now().Format(time.RFC3339),
+		Actor:  actor,
+		Reason: reason,
+	}
+	if status == StatusCancelled {
+		cancellation := Cancellation{Reason: CancelReasonOther, Note: reason}
+		if updated.Cancellation != nil {
+			cancellation = *updated.Cancellation
+		}
+		cancellation.Actor, cancellation.At = actor, change.At
+		updated.Cancellation = &cancellation
+	}
+	updated.Status = status
+	if err := s.commitRecord(walRecord{Op: opPutOrder, Order: &updated, Change: change}); err != nil {
+		return nil, err
+	}
+	orderTransitionsCounter.WithLabelValues(change.From, change.To).Inc()
+
+	return &updated, nil
+}
+
+// GetOrderHistory returns the status changes of an order, oldest first
+func (s *OrderStore) GetOrderHistory(id int) ([]StatusChange, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if _, exists := s.orders[id]; !exists {
+		return nil, ErrOrderNotFound
+	}
+	return append([]StatusChange{}, s.history[id]...), nil
+}
+
+// requestActor identifies the caller of an HTTP request
+func requestActor(r *http.Request) string {
+	if actor := r.Header.Get(actorHeader); actor != "" {
+		return actor
+	}
+	return anonymousActor
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOrderStateMachine(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []string
+		wantErr bool
+	}{
+		{"happy path", []string{StatusProcessing, StatusShipped, StatusDelivered}, false},
+		{"cancel while confirmed", []string{StatusCancelled}, false},
+		{"cancel while processing", []string{StatusProcessing, StatusCancelled}, false},
+		{"cancel after shipping", []string{StatusProcessing, StatusShipped, StatusCancelled}, true},
+		{"skip processing", []string{StatusShipped}, true},
+		{"reopen delivered", []string{StatusProcessing, StatusShipped, StatusDelivered, StatusPending}, true},
+		{"revive cancelled", []string{StatusCancelled, StatusShipped}, true},
+		{"same status", []string{StatusConfirmed}, true},
+	}
+
+	// Every path starts from a paid, confirmed order and ships with a
+	// tracking number
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewOrderStore()
+			payOrder(t, store, 1)
+			var err error
+			for _, status := range tt.path {
+				if status == StatusShipped {
+					_, err = store.ShipOrder(1, "1Z999AA10123456784", "tester", "")
+				} else {
+					_, err = store.TransitionOrder(1, status, "tester", "")
+				}
+				if err != nil {
+					break
+				}
+			}
+			if tt.wantErr != errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("Expected invalid transition %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTransitionOrderRecordsHistory(t *testing.T) {
+	store := NewOrderStore()
+
+	if _, err := store.TransitionOrder(1, StatusProcessing, "alice", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Expected a pending order not to skip confirmation, got %v", err)
+	}
+	payOrder(t, store, 1)
+	if _, err := store.TransitionOrder(1, StatusProcessing, "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.TransitionOrder(1, StatusCancelled, "bob", "out of stock"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.TransitionOrder(1, StatusShipped, "carol", ""); err == nil {
+		t.Fatal("Expected shipping a cancelled order to fail")
+	}
+
+	history, err := store.GetOrderHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 recorded changes, got %+v", history)
+	}
+	first, second := history[1], history[2]
+	if first.From != StatusConfirmed || first.To != StatusProcessing || first.Actor != "alice" || first.At == "" {
+		t.Errorf("Unexpected first change: %+v", first)
+	}
+	if second.To != StatusCancelled || second.Actor != "bob" || second.Reason != "out of stock" {
+		t.Errorf("Unexpected second change: %+v", second)
+	}
+
+	if _, err := store.GetOrderHistory(999); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+	if _, err := store.TransitionOrder(999, StatusProcessing, "alice", ""); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("Expected ErrOrderNotFound, got %v", err)
+	}
+}
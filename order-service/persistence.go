@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"order-service/internal/payment"
+	"order-service/internal/wal"
+)
+
+// defaultSnapshotEvery is the number of logged mutations between snapshots
+const defaultSnapshotEvery = 1000
+
+// Operations recorded in the order write-ahead log
+const (
+	opPutOrder = "put_order"
+)
+
+// walRecord is a single mutation in the order write-ahead log. Every record
+// carries the full state of the order it touches, plus the status change that
+// produced it if any, so an order and its history are updated atomically.
+type walRecord struct {
+	Op     string        `json:"op"`
+	Order  *Order        `json:"order,omitempty"`
+	Change *StatusChange `json:"change,omitempty"`
+}
+
+// storeSnapshot is the full store state written to a snapshot
+type storeSnapshot struct {
+	NextID  int                    `json:"next_id"`
+	Orders  []*Order               `json:"orders"`
+	History map[int][]StatusChange `json:"history,omitempty"`
+}
+
+// orderStoreFromEnv opens a durable store in ORDER_STORE_DIR, or an
+// in-memory store when it is not set
+func orderStoreFromEnv() (*OrderStore, error) {
+	payments, err := payment.NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	rates, err := shippingRatesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := getEnv("ORDER_STORE_DIR", "")
+	if dir == "" {
+		store := newOrderStore()
+		if err := store.seed(); err != nil {
+			return nil, err
+		}
+		store.payments, store.shippingRates = payments, rates
+		return store, nil
+	}
+
+	snapshotEvery, err := strconv.Atoi(getEnv("ORDER_SNAPSHOT_EVERY", strconv.Itoa(defaultSnapshotEvery)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ORDER_SNAPSHOT_EVERY: %w", err)
+	}
+
+	log.Printf("Order store persisted in %s", dir)
+	store, err := OpenOrderStore(dir, snapshotEvery)
+	if err != nil {
+		return nil, err
+	}
+	store.payments, store.shippingRates = payments, rates
+	return store, nil
+}
+
+// OpenOrderStore opens a durable order store whose write-ahead log and
+// snapshots live in dir. Sample data is only added to an empty store.
+func OpenOrderStore(dir string, snapshotEvery int) (*OrderStore, error) {
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+
+	store := newOrderStore()
+	walLog, err := wal.Open(dir, store.restoreSnapshot, store.applyRecord)
+	if err != nil {
+		return nil, err
+	}
+	store.wal = walLog
+	store.snapshotEvery = snapshotEvery
+
+	if len(store.orders) == 0 {
+		if err := store.seed(); err != nil {
+			walLog.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// CheckHealth fails when the store cannot take its lock within ctx or its
+// write-ahead log can no longer be written
+func (s *OrderStore) CheckHealth(ctx context.Context) error {
+	if err := httpx.CheckLock(ctx, s.mutex.RLocker()); err != nil {
+		return fmt.Errorf("store lock: %w", err)
+	}
+	if s.wal == nil {
+		return nil
+	}
+	return s.wal.Ping()
+}
+
+// Close writes a final snapshot and closes the write-ahead log
+func (s *OrderStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+	if err := s.snapshotLocked(); err != nil {
+		s.wal.Close()
+		return err
+	}
+	return s.wal.Close()
+}
+
+// commit logs the new state of order and then installs it in memory. The
+// caller must hold s.mutex.
+func (s *OrderStore) commit(order *Order) error {
+	return s.commitRecord(walRecord{Op: opPutOrder, Order: order})
+}
+
+// commitRecord logs record and then applies it in memory, writing a snapshot
+// when enough records have accumulated. The caller must hold s.mutex.
+func (s *OrderStore) commitRecord(record walRecord) error {
+	if s.wal != nil {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := s.wal.Append(data); err != nil {
+			return err
+		}
+	}
+
+	if err := s.apply(record); err != nil {
+		return err
+	}
+
+	if s.wal != nil && s.wal.Pending() >= s.snapshotEvery {
+		// The record is already durable, so a failed snapshot only delays compaction
+		if err := s.snapshotLocked(); err != nil {
+			log.Printf("Failed to snapshot order store: %v", err)
+		}
+	}
+	return nil
+}
+
+// install stores order in memory, reindexes it and advances nextID past its
+// ID. It fails when a legacy order cannot be migrated.
+func (s *OrderStore) install(order *Order) error {
+	// Orders written before payment tracking have no payment status
+	if order.PaymentStatus == "" {
+		order.PaymentStatus = PaymentStatusPending
+	}
+	// Orders written before line items carry a single product
+	if len(order.Items) == 0 && order.LegacyProduct != "" {
+		if err := migrateLegacyItem(order); err != nil {
+			return err
+		}
+	}
+	if previous, exists := s.orders[order.ID]; exists {
+		s.index.remove(previous)
+	}
+	s.index.add(order)
+	s.orders[order.ID] = order
+	if order.ID >= s.nextID {
+		s.nextID = order.ID + 1
+	}
+	return nil
+}
+
+// snapshotLocked writes the current state as a snapshot. The caller must
+// hold s.mutex.
+func (s *OrderStore) snapshotLocked() error {
+	snapshot := storeSnapshot{
+		NextID:  s.nextID,
+		Orders:  make([]*Order, 0, len(s.orders)),
+		History: s.history,
+	}
+	for _, order := range s.orders {
+		snapshot.Orders = append(snapshot.Orders, order)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.wal.Snapshot(data)
+}
+
+// restoreSnapshot loads the state saved in a snapshot
+func (s *OrderStore) restoreSnapshot(data []byte) error {
+	var snapshot storeSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	s.orders = make(map[int]*Order, len(snapshot.Orders))
+	s.index = newOrderIndex()
+	for _, order := range snapshot.Orders {
+		if err := s.install(order); err != nil {
+			return err
+		}
+	}
+	s.nextID = snapshot.NextID
+	s.history = snapshot.History
+	if s.history == nil {
+		s.history = make(map[int][]StatusChange)
+	}
+	return nil
+}
+
+// applyRecord replays a single write-ahead log record
+func (s *OrderStore) applyRecord(data []byte) error {
+	var record walRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	return s.apply(record)
+}
+
+// apply installs the effect of a record in memory
+func (s *OrderStore) apply(record walRecord) error {
+	switch record.Op {
+	case opPutOrder:
+		if record.Order == nil {
+			return fmt.Errorf("%s record without order", record.Op)
+		}
+		if err := s.install(record.Order); err != nil {
+			return err
+		}
+		if record.Change != nil {
+			s.history[record.Order.ID] = append(s.history[record.Order.ID], *record.Change)
+		}
+	default:
+		return fmt.Errorf("unknown record op %q", record.Op)
+	}
+	return nil
+}
+
+// migrateLegacyItem converts the single product of an order written before
+// line items into its first item. An order that cannot be converted exactly
+// fails the replay rather than being loaded without items.
+func migrateLegacyItem(order *Order) error {
+	price, err := moneyFromFloat(order.LegacyPrice, defaultCurrency)
+	if err != nil {
+		return fmt.Errorf("migrate order %d: price %v: %w", order.ID, order.LegacyPrice, err)
+	}
+	items, total, err := priceItems(singleItem(order.LegacyProduct, order.LegacyQuantity, price))
+	if err != nil {
+		return fmt.Errorf("migrate order %d: %w", order.ID, err)
+	}
+	order.Items, order.Total, order.Currency = items, total, total.Currency
+	order.LegacyProduct, order.LegacyQuantity, order.LegacyPrice = "", 0, 0
+	return nil
+}
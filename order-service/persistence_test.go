@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// crash abandons a durable store without writing a final snapshot
+func crash(t *testing.T, store *OrderStore) {
+	t.Helper()
+	if err := store.wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openTestOrderStore(t *testing.T, dir string, snapshotEvery int) *OrderStore {
+	t.Helper()
+	store, err := OpenOrderStore(dir, snapshotEvery)
+	if err != nil {
+		t.Fatalf("OpenOrderStore failed: %v", err)
+	}
+	return store
+}
+
+func TestOpenOrderStoreSeedsOnce(t *testing.T) {
+	dir := t.TempDir()
+
+	store := openTestOrderStore(t, dir, 100)
+	if len(store.GetAllOrders()) != 2 {
+		t.Fatalf("Expected 2 seeded orders, got %d", len(store.GetAllOrders()))
+	}
+	crash(t, store)
+
+	store = openTestOrderStore(t, dir, 100)
+	defer store.Close()
+	if len(store.GetAllOrders()) != 2 {
+		t.Errorf("Expected seed data not to be duplicated, got %d orders", len(store.GetAllOrders()))
+	}
+}
+
+func TestOrderStoreRecoversAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	store := openTestOrderStore(t, dir, 100)
+	order, err := store.CreateOrder(3, singleItem("Tablet", 1, usd(30000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payOrder(t, store, order.ID)
+	if err := store.UpdateOrderStatus(order.ID, "processing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ShipOrder(order.ID, "TRACK-123", "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+	unchecked, err := store.createOrder(4, singleItem("Pen", 1, usd(150)), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crash(t, store)
+
+	store = openTestOrderStore(t, dir, 100)
+	defer store.Close()
+
+	recovered, exists := store.GetOrder(order.ID)
+	if !exists {
+		t.Fatal("Expected order to survive the crash")
+	}
+	if recovered.Status != "shipped" || recovered.Items[0].Product != "Tablet" || recovered.Shipping.TrackingNumber != "TRACK-123" {
+		t.Errorf("Unexpected recovered order: %+v", recovered)
+	}
+	if flagged, _ := store.GetOrder(unchecked.ID); !flagged.NeedsUserCheck {
+		t.Error("Expected user check flag to survive the crash")
+	}
+	history, err := store.GetOrderHistory(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[1].To != "processing" || history[2].From != "processing" || history[2].To != "shipped" {
+		t.Errorf("Expected transition history to survive the crash, got %+v", history)
+	}
+
+	next, err := store.CreateOrder(3, singleItem("Stylus", 1, usd(3000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != unchecked.ID+1 {
+		t.Errorf("Expected ID %d after recovery, got %d", unchecked.ID+1, next.ID)
+	}
+}
+
+func TestOrderStoreRecoversFromTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	store := openTestOrderStore(t, dir, 100)
+	payOrder(t, store, 2)
+	if err := store.UpdateOrderStatus(2, "processing"); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, store)
+
+	// Simulate a crash partway through the next record: a header promising
+	// more payload than was written
+	f, err := os.OpenFile(filepath.Join(dir, "wal.log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint64(header[0:8], 99)
+	binary.BigEndian.PutUint32(header[8:12], 200)
+	f.Write(header)
+	f.Write([]byte(`{"op":"put_order","order":{"id":2,"status":"deliv`))
+	f.Close()
+
+	store = openTestOrderStore(t, dir, 100)
+	orders := store.GetAllOrders()
+	if len(orders) != 2 {
+		t.Fatalf("Expected 2 orders after recovery, got %d", len(orders))
+	}
+	if order, _ := store.GetOrder(2); order.Status != "processing" {
+		t.Errorf("Expected last committed status 'processing', got %s", order.Status)
+	}
+
+	// The torn record must not hide writes made after recovery
+	if _, err := store.ShipOrder(2, "TRACK-123", "tester", ""); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, store)
+
+	store = openTestOrderStore(t, dir, 100)
+	defer store.Close()
+	if order, _ := store.GetOrder(2); order.Status != "shipped" {
+		t.Errorf("Expected status 'shipped' after second recovery, got %s", order.Status)
+	}
+}
+
+func TestOrderStorePeriodicSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	// Seeding and paying write four records, so the fifth mutation triggers
+	// a snapshot
+	store := openTestOrderStore(t, dir, 5)
+	payOrder(t, store, 1)
+	if err := store.UpdateOrderStatus(1, "processing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "snapshot")); err != nil {
+		t.Fatalf("Expected snapshot to be written: %v", err)
+	}
+	if store.wal.Pending() != 0 {
+		t.Errorf("Expected log to be truncated after snapshot, got %d pending", store.wal.Pending())
+	}
+	if err := store.UpdateOrderStatus(2, "cancelled"); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, store)
+
+	store = openTestOrderStore(t, dir, 5)
+	defer store.Close()
+	if order, _ := store.GetOrder(1); order.Status != "processing" {
+		t.Errorf("Expected snapshotted status 'processing', got %s", order.Status)
+	}
+	if order, _ := store.GetOrder(2); order.Status != "cancelled" {
+		t.Errorf("Expected logged status 'cancelled', got %s", order.Status)
+	}
+	if len(store.GetAllOrders()) != 2 {
+		t.Errorf("Expected 2 orders, got %d", len(store.GetAllOrders()))
+	}
+
+	for id, want := range map[int]int{1: 2, 2: 1} {
+		if history, _ := store.GetOrderHistory(id); len(history) != want {
+			t.Errorf("Expected order %d history to survive, got %+v", id, history)
+		}
+	}
+
+	// Indexes are rebuilt from the snapshot and the replayed log
+	page, err := store.ListOrders(OrderQuery{Status: "processing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Orders) != 1 || page.Orders[0].ID != 1 {
+		t.Errorf("Expected order 1 to be indexed as processing, got %v", page.Orders)
+	}
+}
+
+func TestOrderStoreFromEnv(t *testing.T) {
+	t.Setenv("PAYMENT_PROVIDER", "fake")
+	t.Setenv("ORDER_STORE_DIR", "")
+	store, err := orderStoreFromEnv()
+	if err != nil || store.wal != nil {
+		t.Errorf("Expected in-memory store, got wal=%v err=%v", store.wal, err)
+	}
+
+	t.Setenv("ORDER_STORE_DIR", t.TempDir())
+	t.Setenv("ORDER_SNAPSHOT_EVERY", "nope")
+	if _, err := orderStoreFromEnv(); err == nil {
+		t.Error("Expected error for invalid ORDER_SNAPSHOT_EVERY")
+	}
+}
+
+func TestApplyMigratesSingleProductOrders(t *testing.T) {
+	store := newOrderStore()
+	record := `{"op":"put_order","order":{"id":7,"user_id":1,"product":"Lamp","quantity":2,"price":19.99,"status":"pending","created":"2025-01-01T00:00:00Z"}}`
+	if err := store.applyRecord([]byte(record)); err != nil {
+		t.Fatal(err)
+	}
+
+	order, _ := store.GetOrder(7)
+	if len(order.Items) != 1 || order.Items[0].Product != "Lamp" || order.Items[0].Quantity != 2 {
+		t.Fatalf("Expected the product to become a single item, got %+v", order.Items)
+	}
+	if order.Total.Decimal() != "39.98" || order.LegacyProduct != "" {
+		t.Errorf("Expected total 39.98 and cleared legacy fields, got %s and %q", order.Total, order.LegacyProduct)
+	}
+}
+
+func TestApplyRejectsUnmigratableOrders(t *testing.T) {
+	for _, record := range []string{
+		`{"op":"put_order","order":{"id":7,"user_id":1,"product":"Lamp","quantity":2,"price":19.999,"status":"pending","created":"2025-01-01T00:00:00Z"}}`,
+		`{"op":"put_order","order":{"id":7,"user_id":1,"product":"Lamp","quantity":0,"price":19.99,"status":"pending","created":"2025-01-01T00:00:00Z"}}`,
+	} {
+		store := newOrderStore()
+		err := store.applyRecord([]byte(record))
+		if err == nil || !strings.Contains(err.Error(), "order 7") {
+			t.Errorf("Expected replay to fail naming order 7, got %v", err)
+		}
+		if _, exists := store.GetOrder(7); exists {
+			t.Error("Expected the unmigratable order not to be loaded")
+		}
+	}
+}
@@ -93,7 +93,7 @@ if ($userServiceRunning -and $orderServiceRunning) {
 } else {
     Write-Host "`nTo start the services manually:" -ForegroundColor Yellow
     Write-Host "Terminal 1: cd user-service && go run ." -ForegroundColor White
-    Write-Host "Terminal 2: cd order-service && go run ." -ForegroundColor White
+    Write-Host "Terminal 2: cd order-service && `$env:PAYMENT_PROVIDER='fake'; go run ." -ForegroundColor White
 }
 
 Write-Host "`n=== Session 1 Status: COMPLETED ✓ ===" -ForegroundColor Green
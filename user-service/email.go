@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// maxEmailLength is the longest address accepted, per RFC 5321
+const maxEmailLength = 254
+
+// ErrInvalidEmail is returned when an email address is malformed
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// ErrDuplicateEmail is returned when an email address belongs to another user
+var ErrDuplicateEmail = errors.New("email already in use")
+
+// EmailConflictError reports the user that already owns an email address
+type EmailConflictError struct {
+	Email      string
+	ExistingID int
+}
+
+func (e *EmailConflictError) Error() string {
+	return fmt.Sprintf("email %s already in use by user %d", e.Email, e.ExistingID)
+}
+
+// Is makes EmailConflictError match ErrDuplicateEmail
+func (e *EmailConflictError) Is(target error) bool {
+	return target == ErrDuplicateEmail
+}
+
+// normalizeEmail validates email as a bare RFC 5322 address and returns the
+// lower-cased form used for storage and uniqueness checks
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || len(email) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+
+	addr, err := mail.ParseAddress(email)
+	// Display names such as "John <john@example.com>" are not accepted
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(email), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"john@example.com", "john@example.com", false},
+		{"  John.Doe@Example.COM ", "john.doe@example.com", false},
+		{"first+tag@sub.example.org", "first+tag@sub.example.org", false},
+		{"", "", true},
+		{"not-an-email", "", true},
+		{"missing@", "", true},
+		{"@example.com", "", true},
+		{"two@@example.com", "", true},
+		{"John <john@example.com>", "", true},
+		{"spaces in@example.com", "", true},
+		{strings.Repeat("a", 250) + "@example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeEmail(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidEmail) {
+				t.Errorf("normalizeEmail(%q): expected ErrInvalidEmail, got %q, %v", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeEmail(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestEmailConflictErrorIs(t *testing.T) {
+	var err error = &EmailConflictError{Email: "john@example.com", ExistingID: 1}
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Error("Expected EmailConflictError to match ErrDuplicateEmail")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	pb "user-service/proto"
+)
+
+// errorMetadata returns the ResponseMetadata attached to a status error
+func errorMetadata(t *testing.T, err error) *pb.ResponseMetadata {
+	t.Helper()
+	for _, detail := range status.Convert(err).Details() {
+		if md, ok := detail.(*pb.ResponseMetadata); ok {
+			return md
+		}
+	}
+	t.Fatalf("Expected ResponseMetadata in the details of %v", err)
+	return nil
+}
+
+func TestGRPCErrorMetadata(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), httpx.GRPCRequestIDKey, "req-404")
+	_, err := client.GetUser(ctx, &pb.GetUserRequest{UserId: 999})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound, got %v", err)
+	}
+	md := errorMetadata(t, err)
+	if md.ErrorCode != string(httpx.CodeNotFound) || md.RequestId != "req-404" {
+		t.Errorf("Expected not_found for request req-404, got %v", md)
+	}
+
+	_, err = client.CreateUser(context.Background(), &pb.CreateUserRequest{Name: "Dup", Email: "JOHN@example.com"})
+	if md := errorMetadata(t, err); md.ErrorCode != string(httpx.CodeConflict) {
+		t.Errorf("Expected conflict, got %v", md)
+	}
+	_, err = client.CreateUser(context.Background(), &pb.CreateUserRequest{Name: "Bad", Email: "bad"})
+	if md := errorMetadata(t, err); md.ErrorCode != string(httpx.CodeValidationFailed) {
+		t.Errorf("Expected validation_failed, got %v", md)
+	}
+}
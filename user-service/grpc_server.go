@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "user-service/proto"
+)
+
+// serviceVersion is reported in the metadata of every gRPC response
+const serviceVersion = "1.0.0"
+
+// userStatusToProto maps store statuses to their protobuf values
+var userStatusToProto = map[string]pb.UserStatus{
+	UserStatusActive:    pb.UserStatus_USER_STATUS_ACTIVE,
+	UserStatusInactive:  pb.UserStatus_USER_STATUS_INACTIVE,
+	UserStatusSuspended: pb.UserStatus_USER_STATUS_SUSPENDED,
+}
+
+// userStatusFromProto maps protobuf statuses back to store statuses
+var userStatusFromProto = map[pb.UserStatus]string{
+	pb.UserStatus_USER_STATUS_ACTIVE:    UserStatusActive,
+	pb.UserStatus_USER_STATUS_INACTIVE:  UserStatusInactive,
+	pb.UserStatus_USER_STATUS_SUSPENDED: UserStatusSuspended,
+}
+
+// userGRPCServer implements pb.UserServiceServer on top of UserStore
+type userGRPCServer struct {
+	pb.UnimplementedUserServiceServer
+	store  *UserStore
+	health *httpx.Health
+}
+
+// NewGRPCServer creates a gRPC server exposing the UserService API
+func NewGRPCServer(store *UserStore, health *httpx.Health) *grpc.Server {
+	srv := grpc.NewServer(grpc.UnaryInterceptor(grpcErrors.UnaryInterceptor))
+	pb.RegisterUserServiceServer(srv, &userGRPCServer{store: store, health: health})
+	return srv
+}
+
+// GetUser retrieves a user by ID; inactive users are only returned when
+// include_inactive is set
+func (s *userGRPCServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
+	start := time.Now()
+
+	user, exists := s.store.GetUser(int(req.GetUserId()))
+	if !exists || (user.Status == UserStatusInactive && !req.GetIncludeInactive()) {
+		return nil, status.Errorf(codes.NotFound, "user %d not found", req.GetUserId())
+	}
+
+	return &pb.GetUserResponse{
+		User:     toProtoUser(user),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// ListUsers returns a filtered, sorted page of users. Inactive users are
+// hidden unless the filter asks for a specific status.
+func (s *userGRPCServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	start := time.Now()
+
+	filter := req.GetFilter()
+	query := UserQuery{
+		PageSize:    int(req.GetPageSize()),
+		PageToken:   req.GetPageToken(),
+		EmailDomain: filter.GetEmailDomain(),
+		SearchQuery: filter.GetSearchQuery(),
+		SortBy:      req.GetSortBy(),
+	}
+	if st := filter.GetStatus(); st != pb.UserStatus_USER_STATUS_UNKNOWN {
+		var known bool
+		if query.Status, known = userStatusFromProto[st]; !known {
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported status filter %v", st)
+		}
+	}
+	if filter.GetCreatedAfter() != 0 {
+		query.CreatedAfter = time.Unix(filter.GetCreatedAfter(), 0)
+	}
+	if filter.GetCreatedBefore() != 0 {
+		query.CreatedBefore = time.Unix(filter.GetCreatedBefore(), 0)
+	}
+	if req.GetSortOrder() == pb.SortOrder_SORT_ORDER_DESC {
+		query.SortOrder = SortDesc
+	}
+
+	page, err := s.store.ListUsers(query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &pb.ListUsersResponse{
+		Users:         make([]*pb.User, 0, len(page.Users)),
+		NextPageToken: page.NextPageToken,
+		TotalCount:    int32(page.TotalCount),
+	}
+	for _, user := range page.Users {
+		resp.Users = append(resp.Users, toProtoUser(user))
+	}
+	resp.Metadata = responseMetadata(ctx, start)
+
+	return resp, nil
+}
+
+// CreateUser creates a new user
+func (s *userGRPCServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	start := time.Now()
+
+	if req.GetName() == "" || req.GetEmail() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name and email are required")
+	}
+
+	initialStatus := UserStatusActive
+	if st := req.GetInitialStatus(); st != pb.UserStatus_USER_STATUS_UNKNOWN {
+		var known bool
+		if initialStatus, known = userStatusFromProto[st]; !known {
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported initial status %v", st)
+		}
+	}
+
+	user, err := s.store.CreateUserWithStatus(req.GetName(), req.GetEmail(), initialStatus)
+	if err != nil {
+		return nil, userStoreError(err, "create user")
+	}
+
+	return &pb.CreateUserResponse{
+		User:     toProtoUser(user),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// UpdateUser changes the fields named in the update mask, or both name and
+// email when the mask is empty
+func (s *userGRPCServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	start := time.Now()
+
+	fields := req.GetUpdateMask()
+	if len(fields) == 0 {
+		fields = []string{"name", "email"}
+	}
+
+	var name, email *string
+	for _, field := range fields {
+		switch field {
+		case "name":
+			value := req.GetUser().GetName()
+			name = &value
+		case "email":
+			value := req.GetUser().GetEmail()
+			email = &value
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask field %q", field)
+		}
+	}
+	if (name != nil && *name == "") || (email != nil && *email == "") {
+		return nil, status.Error(codes.InvalidArgument, "name and email cannot be empty")
+	}
+
+	user, err := s.store.PatchUser(int(req.GetUserId()), name, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, status.Errorf(codes.NotFound, "user %d not found", req.GetUserId())
+	}
+	if err != nil {
+		return nil, userStoreError(err, "update user")
+	}
+
+	return &pb.UpdateUserResponse{
+		User:     toProtoUser(user),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// DeleteUser soft-deletes a user like the REST API, erasing it only when
+// hard_delete is set
+func (s *userGRPCServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	start := time.Now()
+
+	err := s.store.DeleteUser(int(req.GetUserId()), req.GetHardDelete())
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, status.Errorf(codes.NotFound, "user %d not found", req.GetUserId())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "delete user: %v", err)
+	}
+
+	return &pb.DeleteUserResponse{
+		Success:  true,
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// HealthCheck reports the readiness of the service and each of its checks
+func (s *userGRPCServer) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	start := time.Now()
+
+	report := s.health.Readiness(ctx)
+	return &pb.HealthCheckResponse{
+		Status:   healthStatusToProto(report),
+		Message:  report.Status,
+		Details:  report.Details(),
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// GetUserMetrics reports user counts and, when start_time is set, a daily
+// breakdown of [start_time, end_time)
+func (s *userGRPCServer) GetUserMetrics(ctx context.Context, req *pb.GetUserMetricsRequest) (*pb.GetUserMetricsResponse, error) {
+	start := time.Now()
+
+	var from, to time.Time
+	if req.GetStartTime() != 0 {
+		from, to = time.Unix(req.GetStartTime(), 0), start
+	}
+	if req.GetEndTime() != 0 {
+		to = time.Unix(req.GetEndTime(), 0)
+	}
+	metrics, err := s.store.UserMetrics(from, to, start)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	out := &pb.UserMetrics{
+		TotalUsers:    int32(metrics.TotalUsers),
+		ActiveUsers:   int32(metrics.ActiveUsers),
+		NewUsersToday: int32(metrics.NewUsersToday),
+		NewUsersWeek:  int32(metrics.NewUsersWeek),
+		NewUsersMonth: int32(metrics.NewUsersMonth),
+	}
+	for _, day := range metrics.DailyStats {
+		out.DailyStats = append(out.DailyStats, &pb.DailyUserStats{
+			Date:         day.Date,
+			NewUsers:     int32(day.NewUsers),
+			ActiveUsers:  int32(day.ActiveUsers),
+			DeletedUsers: int32(day.DeletedUsers),
+		})
+	}
+
+	return &pb.GetUserMetricsResponse{
+		Metrics:  out,
+		Metadata: responseMetadata(ctx, start),
+	}, nil
+}
+
+// userStoreError maps validation and conflict errors from UserStore to gRPC
+// status codes
+func userStoreError(err error, op string) error {
+	switch {
+	case errors.Is(err, ErrInvalidEmail):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrDuplicateEmail):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "%s: %v", op, err)
+	}
+}
+
+// toProtoUser converts a store user into its protobuf representation
+func toProtoUser(user *User) *pb.User {
+	out := &pb.User{
+		Id:     int64(user.ID),
+		Name:   user.Name,
+		Email:  user.Email,
+		Status: userStatusToProto[user.Status],
+	}
+	if created, err := time.Parse(time.RFC3339, user.Created); err == nil {
+		out.CreatedAt = created.Unix()
+		out.UpdatedAt = created.Unix()
+	}
+	if updated, err := time.Parse(time.RFC3339, user.Updated); err == nil {
+		out.UpdatedAt = updated.Unix()
+	}
+	return out
+}
+
+// responseMetadata builds the metadata attached to every gRPC response
+func responseMetadata(ctx context.Context, start time.Time) *pb.ResponseMetadata {
+	return &pb.ResponseMetadata{
+		RequestId:        httpx.GRPCRequestID(ctx),
+		Timestamp:        time.Now().Unix(),
+		ProcessingTimeMs: int32(time.Since(start).Milliseconds()),
+		Version:          serviceVersion,
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	pb "user-service/proto"
+)
+
+// newTestGRPCClient starts an in-process gRPC server backed by store
+func newTestGRPCClient(t *testing.T, store *UserStore) pb.UserServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewGRPCServer(store, httpx.NewHealth("user-service", httpx.HealthConfig{}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewUserServiceClient(conn)
+}
+
+func checkMetadata(t *testing.T, md *pb.ResponseMetadata) {
+	t.Helper()
+	if md == nil {
+		t.Fatal("Expected response metadata, got nil")
+	}
+	if md.RequestId == "" {
+		t.Error("Expected request_id to be set")
+	}
+	if md.Version != serviceVersion {
+		t.Errorf("Expected version %s, got %s", serviceVersion, md.Version)
+	}
+	if md.ProcessingTimeMs < 0 {
+		t.Errorf("Expected non-negative processing time, got %d", md.ProcessingTimeMs)
+	}
+}
+
+func TestGRPCGetUser(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	resp, err := client.GetUser(context.Background(), &pb.GetUserRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	if resp.User.Id != 1 || resp.User.Name != "John Doe" {
+		t.Errorf("Unexpected user: %v", resp.User)
+	}
+	if resp.User.CreatedAt == 0 {
+		t.Error("Expected created_at to be set")
+	}
+	checkMetadata(t, resp.Metadata)
+}
+
+func TestGRPCGetUser_NotFound(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	_, err := client.GetUser(context.Background(), &pb.GetUserRequest{UserId: 999})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestGRPCListUsers(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	resp, err := client.ListUsers(context.Background(), &pb.ListUsersRequest{})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if resp.TotalCount != 2 || len(resp.Users) != 2 {
+		t.Fatalf("Expected 2 users, got %d", len(resp.Users))
+	}
+	if resp.Users[0].Id != 1 || resp.Users[1].Id != 2 {
+		t.Errorf("Expected users ordered by ID, got %d, %d", resp.Users[0].Id, resp.Users[1].Id)
+	}
+	checkMetadata(t, resp.Metadata)
+}
+
+func TestGRPCListUsersPagination(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	resp, err := client.ListUsers(context.Background(), &pb.ListUsersRequest{
+		PageSize:  1,
+		SortOrder: pb.SortOrder_SORT_ORDER_DESC,
+	})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Id != 2 || resp.TotalCount != 2 || resp.NextPageToken == "" {
+		t.Fatalf("Unexpected first page: %v", resp)
+	}
+
+	resp, err = client.ListUsers(context.Background(), &pb.ListUsersRequest{
+		PageSize:  1,
+		PageToken: resp.NextPageToken,
+		SortOrder: pb.SortOrder_SORT_ORDER_DESC,
+	})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Id != 1 || resp.NextPageToken != "" {
+		t.Errorf("Unexpected last page: %v", resp)
+	}
+
+	_, err = client.ListUsers(context.Background(), &pb.ListUsersRequest{SortBy: "age"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGRPCCreateUser(t *testing.T) {
+	store := NewUserStore()
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.CreateUser(context.Background(), &pb.CreateUserRequest{
+		Name:  "Grpc User",
+		Email: "grpc@example.com",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if resp.User.Name != "Grpc User" || resp.User.Email != "grpc@example.com" {
+		t.Errorf("Unexpected user: %v", resp.User)
+	}
+	if _, exists := store.GetUser(int(resp.User.Id)); !exists {
+		t.Error("Expected user to be stored in UserStore")
+	}
+	checkMetadata(t, resp.Metadata)
+}
+
+func TestGRPCCreateUser_MissingFields(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	_, err := client.CreateUser(context.Background(), &pb.CreateUserRequest{Name: "Only Name"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGRPCUpdateUser(t *testing.T) {
+	store := NewUserStore()
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+		UserId:     1,
+		User:       &pb.User{Name: "Renamed", Email: "ignored@example.com"},
+		UpdateMask: []string{"name"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	if resp.User.Name != "Renamed" || resp.User.Email != "john@example.com" {
+		t.Errorf("Expected only the masked field to change, got %v", resp.User)
+	}
+	checkMetadata(t, resp.Metadata)
+
+	_, err = client.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+		UserId:     1,
+		User:       &pb.User{Name: "Renamed"},
+		UpdateMask: []string{"phone"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for unsupported mask, got %v", err)
+	}
+
+	_, err = client.UpdateUser(context.Background(), &pb.UpdateUserRequest{
+		UserId: 999,
+		User:   &pb.User{Name: "A", Email: "a@example.com"},
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestGRPCDeleteUser(t *testing.T) {
+	store := NewUserStore()
+	client := newTestGRPCClient(t, store)
+
+	resp, err := client.DeleteUser(context.Background(), &pb.DeleteUserRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if !resp.Success {
+		t.Error("Expected success")
+	}
+	if _, exists := store.backend.Get(1); !exists {
+		t.Error("Expected soft delete to keep the record")
+	}
+
+	if _, err := client.DeleteUser(context.Background(), &pb.DeleteUserRequest{UserId: 2, HardDelete: true}); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, exists := store.backend.Get(2); exists {
+		t.Error("Expected hard delete to remove the record")
+	}
+
+	_, err = client.DeleteUser(context.Background(), &pb.DeleteUserRequest{UserId: 999})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestGRPCUserStatus(t *testing.T) {
+	store := NewUserStore()
+	client := newTestGRPCClient(t, store)
+
+	if _, err := store.SetUserStatus(1, UserStatusInactive); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.GetUser(context.Background(), &pb.GetUserRequest{UserId: 1})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected inactive user to be hidden, got %v", err)
+	}
+	resp, err := client.GetUser(context.Background(), &pb.GetUserRequest{UserId: 1, IncludeInactive: true})
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	if resp.User.Status != pb.UserStatus_USER_STATUS_INACTIVE {
+		t.Errorf("Expected INACTIVE, got %v", resp.User.Status)
+	}
+
+	list, err := client.ListUsers(context.Background(), &pb.ListUsersRequest{})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if len(list.Users) != 1 || list.Users[0].Id != 2 {
+		t.Errorf("Expected only the active user, got %v", list.Users)
+	}
+	list, err = client.ListUsers(context.Background(), &pb.ListUsersRequest{
+		Filter: &pb.UserFilter{Status: pb.UserStatus_USER_STATUS_INACTIVE},
+	})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if len(list.Users) != 1 || list.Users[0].Id != 1 {
+		t.Errorf("Expected only the inactive user, got %v", list.Users)
+	}
+
+	created, err := client.CreateUser(context.Background(), &pb.CreateUserRequest{
+		Name:          "Suspended",
+		Email:         "suspended@example.com",
+		InitialStatus: pb.UserStatus_USER_STATUS_SUSPENDED,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if created.User.Status != pb.UserStatus_USER_STATUS_SUSPENDED {
+		t.Errorf("Expected SUSPENDED, got %v", created.User.Status)
+	}
+}
+
+func TestGRPCCreateUser_DuplicateEmail(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	_, err := client.CreateUser(context.Background(), &pb.CreateUserRequest{Name: "Dup", Email: "JOHN@example.com"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists, got %v", err)
+	}
+	_, err = client.CreateUser(context.Background(), &pb.CreateUserRequest{Name: "Bad", Email: "bad"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	resp, err := client.HealthCheck(context.Background(), &pb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("HealthCheck failed: %v", err)
+	}
+	if resp.Status != pb.HealthStatus_HEALTH_STATUS_SERVING {
+		t.Errorf("Expected SERVING, got %v", resp.Status)
+	}
+	checkMetadata(t, resp.Metadata)
+}
+
+func TestGRPCGetUserMetrics(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	resp, err := client.GetUserMetrics(context.Background(), &pb.GetUserMetricsRequest{})
+	if err != nil {
+		t.Fatalf("GetUserMetrics failed: %v", err)
+	}
+	if resp.Metrics.TotalUsers != 2 || resp.Metrics.NewUsersToday != 2 || len(resp.Metrics.DailyStats) != 0 {
+		t.Errorf("Unexpected metrics: %v", resp.Metrics)
+	}
+	checkMetadata(t, resp.Metadata)
+
+	now := time.Now()
+	resp, err = client.GetUserMetrics(context.Background(), &pb.GetUserMetricsRequest{StartTime: now.Add(-48 * time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("GetUserMetrics failed: %v", err)
+	}
+	days := resp.Metrics.DailyStats
+	if len(days) != 3 || days[2].Date != now.UTC().Format("2006-01-02") || days[2].NewUsers != 2 || days[0].ActiveUsers != 0 {
+		t.Errorf("Expected three days ending today, got %v", days)
+	}
+
+	_, err = client.GetUserMetrics(context.Background(), &pb.GetUserMetricsRequest{StartTime: now.Unix(), EndTime: now.Add(-time.Hour).Unix()})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an empty range, got %v", err)
+	}
+}
+
+func TestGRPCRequestIDPropagation(t *testing.T) {
+	client := newTestGRPCClient(t, NewUserStore())
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), httpx.GRPCRequestIDKey, "req-123")
+	resp, err := client.GetUser(ctx, &pb.GetUserRequest{UserId: 1})
+	if err != nil {
+		t.Fatalf("GetUser failed: %v", err)
+	}
+	if resp.Metadata.RequestId != "req-123" {
+		t.Errorf("Expected request_id req-123, got %s", resp.Metadata.RequestId)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	pb "user-service/proto"
+)
+
+// newServiceHealth creates the health checks of user-service. The store is
+// required for readiness.
+func newServiceHealth(store *UserStore, config httpx.HealthConfig) *httpx.Health {
+	health := httpx.NewHealth("user-service", config)
+	health.Register("store", httpx.Readiness, httpx.CheckerFunc(store.CheckHealth))
+	return health
+}
+
+// CheckHealth fails when the store cannot take its lock within ctx or its
+// backend can no longer persist users
+func (s *UserStore) CheckHealth(ctx context.Context) error {
+	if err := httpx.CheckLock(ctx, s.mutex.RLocker()); err != nil {
+		return fmt.Errorf("store lock: %w", err)
+	}
+	return s.backend.Ping()
+}
+
+// healthStatusToProto maps a readiness report onto the proto enum; degraded
+// services are still serving
+func healthStatusToProto(report httpx.HealthReport) pb.HealthStatus {
+	if report.Status == httpx.StatusUnhealthy {
+		return pb.HealthStatus_HEALTH_STATUS_NOT_SERVING
+	}
+	return pb.HealthStatus_HEALTH_STATUS_SERVING
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
+	pb "user-service/proto"
+)
+
+func TestUserStoreCheckHealth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+	backend, err := OpenFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	store, err := NewUserStoreWithBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.CheckHealth(context.Background()); err != nil {
+		t.Fatalf("Expected a healthy store, got %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CheckHealth(context.Background()); err == nil {
+		t.Error("Expected a store whose log was removed to be unhealthy")
+	}
+
+	store.mutex.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = store.CheckHealth(ctx)
+	store.mutex.Unlock()
+	if err == nil {
+		t.Error("Expected a store whose lock is held to be unhealthy")
+	}
+}
+
+func TestGRPCHealthCheckReportsChecks(t *testing.T) {
+	health := httpx.NewHealth("user-service", httpx.HealthConfig{})
+	health.Register("store", httpx.Readiness, httpx.CheckerFunc(func(ctx context.Context) error { return os.ErrClosed }))
+	srv := &userGRPCServer{store: NewUserStore(), health: health}
+
+	resp, err := srv.HealthCheck(context.Background(), &pb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != pb.HealthStatus_HEALTH_STATUS_NOT_SERVING || resp.Details["store"] != "unhealthy: file already closed" {
+		t.Errorf("Expected NOT_SERVING with the store error, got %v %v", resp.Status, resp.Details)
+	}
+}
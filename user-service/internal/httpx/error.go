@@ -1,11 +0,0 @@
-package httpx
-
-import (
-	"encoding/json"
-	"net/http"
-)
-
-func WriteError(w http.ResponseWriter, status int, msg string) {
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{"error": msg})
-} 
\ No newline at end of file
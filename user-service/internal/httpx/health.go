@@ -1,21 +0,0 @@
-package httpx
-
-import (
-	"encoding/json"
-	"net/http"
-)
-
-type healthResponse struct {
-	Status  string `json:"status"`
-	Service string `json:"service"`
-}
-
-func NewHealthHandler(serviceName string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(healthResponse{
-			Status:  "healthy",
-			Service: serviceName,
-		})
-	}
-} 
\ No newline at end of file
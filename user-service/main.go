@@ -21,16 +21,21 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"user-service/internal/httpx"
 )
 
 // User represents a user in the system
@@ -38,14 +43,36 @@ type User struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
+	Status   string `json:"status"`
 	Created  string `json:"created"`
+	Updated  string `json:"updated,omitempty"`
+	Deleted  string `json:"deleted,omitempty"`
 }
 
-// UserStore provides in-memory storage for users
+// User lifecycle statuses
+const (
+	UserStatusActive    = "active"
+	UserStatusInactive  = "inactive"
+	UserStatusSuspended = "suspended"
+)
+
+// userTransitions lists the statuses a user may move to from each status
+var userTransitions = map[string][]string{
+	UserStatusActive:    {UserStatusInactive, UserStatusSuspended},
+	UserStatusInactive:  {UserStatusActive, UserStatusSuspended},
+	UserStatusSuspended: {UserStatusActive},
+}
+
+// ErrInvalidTransition is returned when a user cannot move to the requested status
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// UserStore provides user operations on top of a pluggable UserBackend
 type UserStore struct {
-	users map[int]*User
-	mutex sync.RWMutex
-	nextID int
+	backend UserBackend
+	mutex   sync.RWMutex
+	
+	// emails maps the normalized email of every live user to its ID
+	emails map[string]int
 }
 
 // Prometheus metrics
@@ -72,69 +99,257 @@ func init() {
 	prometheus.MustRegister(httpDuration)
 }
 
-// NewUserStore creates a new user store
+// NewUserStore creates a new user store backed by memory
 func NewUserStore() *UserStore {
-	store := &UserStore{
-		users:  make(map[int]*User),
-		nextID: 1,
+	store, err := NewUserStoreWithBackend(NewMemoryBackend())
+	if err != nil {
+		log.Fatal("Failed to create user store:", err)
 	}
+	return store
+}
+
+// NewUserStoreWithBackend creates a user store on top of backend, adding
+// sample data when the backend is empty
+func NewUserStoreWithBackend(backend UserBackend) (*UserStore, error) {
+	store := &UserStore{backend: backend, emails: make(map[string]int)}
 	
-	// Add some sample data
-	store.CreateUser("John Doe", "john@example.com")
-	store.CreateUser("Jane Smith", "jane@example.com")
+	for _, user := range backend.List() {
+		if user.Deleted != "" {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(user.Email))
+		if existing, taken := store.emails[key]; !taken || user.ID < existing {
+			store.emails[key] = user.ID
+		}
+	}
 	
-	return store
+	if len(backend.List()) == 0 {
+		// Add some sample data
+		if _, err := store.CreateUser("John Doe", "john@example.com"); err != nil {
+			return nil, err
+		}
+		if _, err := store.CreateUser("Jane Smith", "jane@example.com"); err != nil {
+			return nil, err
+		}
+	}
+	
+	return store, nil
 }
 
-// CreateUser creates a new user
-func (s *UserStore) CreateUser(name, email string) *User {
+// CreateUser creates a new active user
+func (s *UserStore) CreateUser(name, email string) (*User, error) {
+	return s.CreateUserWithStatus(name, email, UserStatusActive)
+}
+
+// CreateUserWithStatus creates a new user with the given initial status
+func (s *UserStore) CreateUserWithStatus(name, email, status string) (*User, error) {
+	if _, known := userTransitions[status]; !known {
+		return nil, fmt.Errorf("unknown user status %q", status)
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
+	if existing, taken := s.emails[email]; taken {
+		return nil, &EmailConflictError{Email: email, ExistingID: existing}
+	}
+	
 	user := &User{
-		ID:      s.nextID,
 		Name:    name,
 		Email:   email,
+		Status:  status,
 		Created: time.Now().Format(time.RFC3339),
 	}
 	
-	s.users[user.ID] = user
-	s.nextID++
+	if err := s.backend.Create(user); err != nil {
+		return nil, err
+	}
+	s.emails[email] = user.ID
 	
-	return user
+	return user, nil
 }
 
-// GetUser retrieves a user by ID
+// GetUser retrieves a user by ID, hiding soft-deleted users
 func (s *UserStore) GetUser(id int) (*User, bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	
-	user, exists := s.users[id]
-	return user, exists
+	user, exists := s.backend.Get(id)
+	if !exists || user.Deleted != "" {
+		return nil, false
+	}
+	return withDefaultStatus(user), true
 }
 
-// GetAllUsers retrieves all users
+// GetAllUsers retrieves all users that have not been deleted
 func (s *UserStore) GetAllUsers() []*User {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	
-	users := make([]*User, 0, len(s.users))
-	for _, user := range s.users {
-		users = append(users, user)
+	users := make([]*User, 0)
+	for _, user := range s.backend.List() {
+		if user.Deleted == "" {
+			users = append(users, withDefaultStatus(user))
+		}
 	}
-	
 	return users
 }
 
+// UpdateUser replaces the name and email of a user
+func (s *UserStore) UpdateUser(id int, name, email string) (*User, error) {
+	return s.PatchUser(id, &name, &email)
+}
+
+// PatchUser changes the fields of a user that are not nil
+func (s *UserStore) PatchUser(id int, name, email *string) (*User, error) {
+	var normalized string
+	if email != nil {
+		var err error
+		if normalized, err = normalizeEmail(*email); err != nil {
+			return nil, err
+		}
+	}
+	
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	user, exists := s.backend.Get(id)
+	if !exists || user.Deleted != "" {
+		return nil, ErrUserNotFound
+	}
+	previous := strings.ToLower(strings.TrimSpace(user.Email))
+	
+	if name != nil {
+		user.Name = *name
+	}
+	if email != nil {
+		if existing, taken := s.emails[normalized]; taken && existing != id {
+			return nil, &EmailConflictError{Email: normalized, ExistingID: existing}
+		}
+		user.Email = normalized
+	}
+	user.Updated = time.Now().Format(time.RFC3339)
+	
+	if err := s.backend.Update(user); err != nil {
+		return nil, err
+	}
+	if email != nil {
+		s.releaseEmail(previous, id)
+		s.emails[normalized] = id
+	}
+	
+	return user, nil
+}
+
+// releaseEmail frees an email address if it is held by the user with id
+func (s *UserStore) releaseEmail(email string, id int) {
+	if s.emails[email] == id {
+		delete(s.emails, email)
+	}
+}
+
+// SetUserStatus moves a user to status if the lifecycle allows it
+func (s *UserStore) SetUserStatus(id int, status string) (*User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	user, exists := s.backend.Get(id)
+	if !exists || user.Deleted != "" {
+		return nil, ErrUserNotFound
+	}
+	user = withDefaultStatus(user)
+	
+	if !canTransition(user.Status, status) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrInvalidTransition, user.Status, status)
+	}
+	user.Status = status
+	user.Updated = time.Now().Format(time.RFC3339)
+	
+	if err := s.backend.Update(user); err != nil {
+		return nil, err
+	}
+	
+	return user, nil
+}
+
+// canTransition reports whether a user may move from one status to another
+func canTransition(from, to string) bool {
+	for _, allowed := range userTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaultStatus treats users stored before statuses existed as active
+func withDefaultStatus(user *User) *User {
+	if user.Status == "" {
+		user.Status = UserStatusActive
+	}
+	return user
+}
+
+// DeleteUser removes a user. A soft delete keeps the record with a deletion
+// timestamp; a hard delete erases it, including previously soft-deleted users.
+func (s *UserStore) DeleteUser(id int, hard bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	user, exists := s.backend.Get(id)
+	if !exists {
+		return ErrUserNotFound
+	}
+	
+	if hard {
+		if err := s.backend.Delete(id); err != nil {
+			return err
+		}
+	} else {
+		if user.Deleted != "" {
+			return ErrUserNotFound
+		}
+		user.Deleted = time.Now().Format(time.RFC3339)
+		if err := s.backend.Update(user); err != nil {
+			return err
+		}
+	}
+	
+	// Deleted users no longer reserve their email address
+	s.releaseEmail(strings.ToLower(strings.TrimSpace(user.Email)), id)
+	return nil
+}
+
 // HTTP Handlers
 func (s *UserStore) handleGetUsers(w http.ResponseWriter, r *http.Request) {
 	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/users"))
 	defer timer.ObserveDuration()
 	
-	users := s.GetAllUsers()
+	query, err := ParseUserQuery(r.URL.Query())
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/users", "400").Inc()
+		return
+	}
+	
+	page, err := s.ListUsers(query)
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/users", "400").Inc()
+		return
+	}
 	
+	// The body stays a plain array; paging details travel in headers
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.TotalCount))
+	if page.NextPageToken != "" {
+		w.Header().Set("X-Next-Page-Token", page.NextPageToken)
+	}
+	json.NewEncoder(w).Encode(page.Users)
 	
 	httpRequests.WithLabelValues(r.Method, "/users", "200").Inc()
 }
@@ -146,14 +361,14 @@ func (s *UserStore) handleGetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		httpx.WriteError(w, http.StatusBadRequest, "Invalid user ID")
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid user ID")
 		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
 		return
 	}
 	
 	user, exists := s.GetUser(id)
 	if !exists {
-		httpx.WriteError(w, http.StatusNotFound, "User not found")
+		httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "User not found")
 		httpRequests.WithLabelValues(r.Method, "/users/{id}", "404").Inc()
 		return
 	}
@@ -174,18 +389,36 @@ func (s *UserStore) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpx.WriteError(w, http.StatusBadRequest, "Invalid JSON")
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid JSON")
 		httpRequests.WithLabelValues(r.Method, "/users", "400").Inc()
 		return
 	}
 	
-	if req.Name == "" || req.Email == "" {
-		httpx.WriteError(w, http.StatusBadRequest, "Name and email are required")
+	if fields := requireNameAndEmail(req.Name, req.Email); len(fields) > 0 {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "Name and email are required", fields...)
 		httpRequests.WithLabelValues(r.Method, "/users", "400").Inc()
 		return
 	}
 	
-	user := s.CreateUser(req.Name, req.Email)
+	user, err := s.CreateUser(req.Name, req.Email)
+	if errors.Is(err, ErrInvalidEmail) {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "Invalid email address",
+			httpx.FieldError{Field: "email", Message: "is not a valid email address"})
+		httpRequests.WithLabelValues(r.Method, "/users", "400").Inc()
+		return
+	}
+	var conflict *EmailConflictError
+	if errors.As(err, &conflict) {
+		writeEmailConflict(w, r, conflict)
+		httpRequests.WithLabelValues(r.Method, "/users", "409").Inc()
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create user: %v", err)
+		httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to create user")
+		httpRequests.WithLabelValues(r.Method, "/users", "500").Inc()
+		return
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -194,27 +427,335 @@ func (s *UserStore) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	httpRequests.WithLabelValues(r.Method, "/users", "201").Inc()
 }
 
+func (s *UserStore) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/users/{id}"))
+	defer timer.ObserveDuration()
+	
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid user ID")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	
+	var req struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid JSON")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	
+	if fields := requireNameAndEmail(req.Name, req.Email); len(fields) > 0 {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "Name and email are required", fields...)
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	
+	user, err := s.UpdateUser(id, req.Name, req.Email)
+	s.writeUpdateResult(w, r, user, err)
+}
+
+func (s *UserStore) handlePatchUser(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/users/{id}"))
+	defer timer.ObserveDuration()
+	
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid user ID")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	
+	var req struct {
+		Name  *string `json:"name"`
+		Email *string `json:"email"`
+	}
+	
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid JSON")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	
+	if req.Name == nil && req.Email == nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "Name or email is required")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	var fields []httpx.FieldError
+	if req.Name != nil && *req.Name == "" {
+		fields = append(fields, httpx.FieldError{Field: "name", Message: "cannot be empty"})
+	}
+	if req.Email != nil && *req.Email == "" {
+		fields = append(fields, httpx.FieldError{Field: "email", Message: "cannot be empty"})
+	}
+	if len(fields) > 0 {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "Name and email cannot be empty", fields...)
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	
+	user, err := s.PatchUser(id, req.Name, req.Email)
+	s.writeUpdateResult(w, r, user, err)
+}
+
+// writeUpdateResult writes the response shared by PUT and PATCH
+func (s *UserStore) writeUpdateResult(w http.ResponseWriter, r *http.Request, user *User, err error) {
+	if errors.Is(err, ErrUserNotFound) {
+		httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "User not found")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "404").Inc()
+		return
+	}
+	if errors.Is(err, ErrInvalidEmail) {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeValidationFailed, "Invalid email address",
+			httpx.FieldError{Field: "email", Message: "is not a valid email address"})
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	var conflict *EmailConflictError
+	if errors.As(err, &conflict) {
+		writeEmailConflict(w, r, conflict)
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "409").Inc()
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update user: %v", err)
+		httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to update user")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "500").Inc()
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+	
+	httpRequests.WithLabelValues(r.Method, "/users/{id}", "200").Inc()
+}
+
+func (s *UserStore) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/users/{id}"))
+	defer timer.ObserveDuration()
+	
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid user ID")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+		return
+	}
+	
+	hard := false
+	if value := r.URL.Query().Get("hard"); value != "" {
+		hard, err = strconv.ParseBool(value)
+		if err != nil {
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid hard parameter")
+			httpRequests.WithLabelValues(r.Method, "/users/{id}", "400").Inc()
+			return
+		}
+	}
+	
+	if err := s.DeleteUser(id, hard); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "User not found")
+			httpRequests.WithLabelValues(r.Method, "/users/{id}", "404").Inc()
+			return
+		}
+		log.Printf("Failed to delete user: %v", err)
+		httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to delete user")
+		httpRequests.WithLabelValues(r.Method, "/users/{id}", "500").Inc()
+		return
+	}
+	
+	w.WriteHeader(http.StatusNoContent)
+	
+	httpRequests.WithLabelValues(r.Method, "/users/{id}", "204").Inc()
+}
+
+// requireNameAndEmail returns a field error for each of name and email that is empty
+func requireNameAndEmail(name, email string) []httpx.FieldError {
+	var fields []httpx.FieldError
+	if name == "" {
+		fields = append(fields, httpx.FieldError{Field: "name", Message: "is required"})
+	}
+	if email == "" {
+		fields = append(fields, httpx.FieldError{Field: "email", Message: "is required"})
+	}
+	return fields
+}
+
+// writeEmailConflict reports which user already owns an email address
+func writeEmailConflict(w http.ResponseWriter, r *http.Request, conflict *EmailConflictError) {
+	problem := httpx.NewProblem(r, http.StatusConflict, httpx.CodeConflict, "Email already in use",
+		httpx.FieldError{Field: "email", Message: "is already in use"})
+	problem.Extensions = map[string]interface{}{"existing_user_id": conflict.ExistingID}
+	httpx.WriteProblem(w, problem)
+}
+
+// handleUserTransition returns a handler that moves a user to status
+func (s *UserStore) handleUserTransition(status, endpoint string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, endpoint))
+		defer timer.ObserveDuration()
+		
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, "Invalid user ID")
+			httpRequests.WithLabelValues(r.Method, endpoint, "400").Inc()
+			return
+		}
+		
+		user, err := s.SetUserStatus(id, status)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			httpx.WriteError(w, r, http.StatusNotFound, httpx.CodeNotFound, "User not found")
+			httpRequests.WithLabelValues(r.Method, endpoint, "404").Inc()
+			return
+		case errors.Is(err, ErrInvalidTransition):
+			httpx.WriteError(w, r, http.StatusConflict, httpx.CodeInvalidTransition, "Cannot change user status: "+err.Error())
+			httpRequests.WithLabelValues(r.Method, endpoint, "409").Inc()
+			return
+		case err != nil:
+			log.Printf("Failed to change user status: %v", err)
+			httpx.WriteError(w, r, http.StatusInternalServerError, httpx.CodeInternal, "Failed to change user status")
+			httpRequests.WithLabelValues(r.Method, endpoint, "500").Inc()
+			return
+		}
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+		
+		httpRequests.WithLabelValues(r.Method, endpoint, "200").Inc()
+	}
+}
+
+func (s *UserStore) handleGetUserMetrics(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(httpDuration.WithLabelValues(r.Method, "/users/metrics"))
+	defer timer.ObserveDuration()
+	
+	now := time.Now()
+	start, end, err := ParseUserMetricsRange(r.URL.Query(), now)
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/users/metrics", "400").Inc()
+		return
+	}
+	
+	metrics, err := s.UserMetrics(start, end, now)
+	if err != nil {
+		httpx.WriteError(w, r, http.StatusBadRequest, httpx.CodeInvalidRequest, err.Error())
+		httpRequests.WithLabelValues(r.Method, "/users/metrics", "400").Inc()
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+	
+	httpRequests.WithLabelValues(r.Method, "/users/metrics", "200").Inc()
+}
+
+// defaultCORSConfig allows any origin without credentials, as before the
+// policy became configurable
+var defaultCORSConfig = httpx.CORSConfig{
+	AllowedOrigins: []string{"*"},
+	AllowedHeaders: []string{"Content-Type", "Authorization", "Idempotency-Key", httpx.RequestIDHeader},
+	ExposedHeaders: []string{"X-Total-Count", "X-Next-Page-Token", httpx.IdempotentReplayedHeader, httpx.RequestIDHeader},
+	MaxAge:         10 * time.Minute,
+}
+
+// defaultIdempotencyTTL is how long responses to requests with an
+// Idempotency-Key are kept when IDEMPOTENCY_TTL is not set
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyTTLFromEnv reads IDEMPOTENCY_TTL as a Go duration such as "1h"
+func idempotencyTTLFromEnv() time.Duration {
+	value := os.Getenv("IDEMPOTENCY_TTL")
+	if value == "" {
+		return defaultIdempotencyTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Invalid IDEMPOTENCY_TTL %q, using %s", value, defaultIdempotencyTTL)
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func main() {
-	store := NewUserStore()
+	backend, err := NewUserBackend(getEnv("USER_STORE_BACKEND", BackendMemory), getEnv("USER_STORE_PATH", "data/users.log"))
+	if err != nil {
+		log.Fatal("Failed to open user store:", err)
+	}
+	defer backend.Close()
+	
+	store, err := NewUserStoreWithBackend(backend)
+	if err != nil {
+		log.Fatal("Failed to create user store:", err)
+	}
+	prometheus.MustRegister(NewUserMetricsCollector(store))
+	
+	corsConfig, err := httpx.CORSConfigFromEnv(defaultCORSConfig)
+	if err != nil {
+		log.Fatal("Invalid CORS settings:", err)
+	}
+	idempotency := httpx.NewIdempotency(idempotencyTTLFromEnv())
+	healthConfig, err := httpx.HealthConfigFromEnv()
+	if err != nil {
+		log.Fatal("Invalid health check settings:", err)
+	}
+	health := newServiceHealth(store, healthConfig)
 	
-	r := mux.NewRouter()
-	r.Use(httpx.CORSMiddleware)
-	r.HandleFunc("/health", httpx.NewHealthHandler("user-service")).Methods("GET")
-	r.HandleFunc("/author", httpx.AuthorHandler).Methods("GET")
+	r := httpx.NewRouter(health)
 	r.HandleFunc("/users", store.handleGetUsers).Methods("GET")
+	r.HandleFunc("/users/metrics", store.handleGetUserMetrics).Methods("GET")
 	r.HandleFunc("/users/{id:[0-9]+}", store.handleGetUser).Methods("GET")
-	r.HandleFunc("/users", store.handleCreateUser).Methods("POST")
+	r.Handle("/users", idempotency.Middleware(http.HandlerFunc(store.handleCreateUser))).Methods("POST")
+	r.HandleFunc("/users/{id:[0-9]+}", store.handleUpdateUser).Methods("PUT")
+	r.HandleFunc("/users/{id:[0-9]+}", store.handlePatchUser).Methods("PATCH")
+	r.HandleFunc("/users/{id:[0-9]+}", store.handleDeleteUser).Methods("DELETE")
+	r.HandleFunc("/users/{id:[0-9]+}/activate", store.handleUserTransition(UserStatusActive, "/users/{id}/activate")).Methods("POST")
+	r.HandleFunc("/users/{id:[0-9]+}/deactivate", store.handleUserTransition(UserStatusInactive, "/users/{id}/deactivate")).Methods("POST")
+	r.HandleFunc("/users/{id:[0-9]+}/suspend", store.handleUserTransition(UserStatusSuspended, "/users/{id}/suspend")).Methods("POST")
 	
 	// Metrics endpoint
 	r.Handle("/metrics", promhttp.Handler())
 	
+	grpcPort := ":" + getEnv("GRPC_PORT", "50051")
+	lis, err := net.Listen("tcp", grpcPort)
+	if err != nil {
+		log.Fatal("gRPC listener failed:", err)
+	}
+	grpcServer := NewGRPCServer(store, health)
+	var grpcWorker httpx.Worker
+	grpcWorker.Go(func() error {
+		log.Printf("gRPC server listening on port %s", grpcPort)
+		err := grpcServer.Serve(lis)
+		log.Printf("gRPC server stopped: %v", err)
+		return err
+	})
+	// A stopped gRPC server fails liveness so that the pod is restarted
+	health.Register("grpc", httpx.Liveness, &grpcWorker)
+	
 	port := ":8080"
 	log.Printf("User Service starting on port %s", port)
-	log.Printf("Health check: http://localhost%s/health", port)
+	log.Printf("Health checks: http://localhost%s/livez and /readyz", port)
 	log.Printf("API endpoint: http://localhost%s/users", port)
 	log.Printf("Metrics: http://localhost%s/metrics", port)
 	
-	if err := http.ListenAndServe(port, r); err != nil {
+	if err := httpx.ListenAndServe(port, r, corsConfig); err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
 } 
\ No newline at end of file
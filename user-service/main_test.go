@@ -3,12 +3,15 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dev-shiki/devops-portfolio-platform/httpx"
 	"github.com/gorilla/mux"
-	"user-service/internal/httpx"
 )
 
 func TestNewUserStore(t *testing.T) {
@@ -18,19 +21,26 @@ func TestNewUserStore(t *testing.T) {
 		t.Fatal("NewUserStore() returned nil")
 	}
 	
-	if len(store.users) != 2 {
-		t.Errorf("Expected 2 initial users, got %d", len(store.users))
+	if users := store.GetAllUsers(); len(users) != 2 {
+		t.Errorf("Expected 2 initial users, got %d", len(users))
 	}
 	
-	if store.nextID != 3 {
-		t.Errorf("Expected nextID to be 3, got %d", store.nextID)
+	user, err := store.CreateUser("Next User", "next@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 3 {
+		t.Errorf("Expected next ID to be 3, got %d", user.ID)
 	}
 }
 
 func TestCreateUser(t *testing.T) {
 	store := NewUserStore()
 	
-	user := store.CreateUser("Test User", "test@example.com")
+	user, err := store.CreateUser("Test User", "test@example.com")
+	if err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
 	
 	if user == nil {
 		t.Fatal("CreateUser() returned nil")
@@ -74,25 +84,32 @@ func TestHealthHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 	
+	store := NewUserStore()
+	
 	rr := httptest.NewRecorder()
-	handler := httpx.NewHealthHandler("user-service")
-	handler(rr, req)
+	handler := http.HandlerFunc(newServiceHealth(store, httpx.HealthConfig{}).ReadinessHandler)
+	
+	handler.ServeHTTP(rr, req)
 	
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, status)
 	}
 	
-	var response map[string]string
+	var response httpx.HealthReport
 	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
 		t.Fatal("Failed to parse JSON response")
 	}
 	
-	if response["status"] != "healthy" {
-		t.Errorf("Expected status 'healthy', got %s", response["status"])
+	if response.Status != "healthy" {
+		t.Errorf("Expected status 'healthy', got %s", response.Status)
+	}
+	
+	if response.Service != "user-service" {
+		t.Errorf("Expected service 'user-service', got %s", response.Service)
 	}
 	
-	if response["service"] != "user-service" {
-		t.Errorf("Expected service 'user-service', got %s", response["service"])
+	if len(response.Checks) != 1 || response.Checks[0].Name != "store" {
+		t.Errorf("Expected the store check, got %+v", response.Checks)
 	}
 }
 
@@ -250,6 +267,387 @@ func TestHandleGetUser_NotFound(t *testing.T) {
 	}
 }
 
+// serveUser routes a request for /users/{id} to handler
+func serveUser(handler http.HandlerFunc, method, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/users/"+id, bytes.NewBufferString(body))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestUpdateAndPatchUser(t *testing.T) {
+	store := NewUserStore()
+	
+	user, err := store.UpdateUser(1, "John Updated", "john.updated@example.com")
+	if err != nil {
+		t.Fatalf("UpdateUser() failed: %v", err)
+	}
+	if user.Name != "John Updated" || user.Email != "john.updated@example.com" || user.Updated == "" {
+		t.Errorf("Unexpected updated user: %+v", user)
+	}
+	
+	name := "Johnny"
+	user, err = store.PatchUser(1, &name, nil)
+	if err != nil {
+		t.Fatalf("PatchUser() failed: %v", err)
+	}
+	if user.Name != "Johnny" || user.Email != "john.updated@example.com" {
+		t.Errorf("Expected only the name to change, got %+v", user)
+	}
+	
+	if _, err := store.UpdateUser(999, "Nobody", "nobody@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	store := NewUserStore()
+	
+	if err := store.DeleteUser(1, false); err != nil {
+		t.Fatalf("Soft delete failed: %v", err)
+	}
+	if _, exists := store.GetUser(1); exists {
+		t.Error("Expected soft-deleted user to be hidden")
+	}
+	if users := store.GetAllUsers(); len(users) != 1 {
+		t.Errorf("Expected 1 visible user, got %d", len(users))
+	}
+	if stored, _ := store.backend.Get(1); stored == nil || stored.Deleted == "" {
+		t.Error("Expected soft-deleted user to be kept with a deletion timestamp")
+	}
+	if err := store.DeleteUser(1, false); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected second soft delete to report not found, got %v", err)
+	}
+	if _, err := store.UpdateUser(1, "Ghost", "ghost@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected update of deleted user to fail, got %v", err)
+	}
+	
+	// A hard delete also purges users that were soft-deleted earlier
+	if err := store.DeleteUser(1, true); err != nil {
+		t.Fatalf("Hard delete failed: %v", err)
+	}
+	if _, exists := store.backend.Get(1); exists {
+		t.Error("Expected hard-deleted user to be removed from the backend")
+	}
+	if err := store.DeleteUser(1, true); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestHandleUpdateUser(t *testing.T) {
+	store := NewUserStore()
+	
+	rr := serveUser(store.handleUpdateUser, "PUT", "2", `{"name":"Jane Doe","email":"jane.doe@example.com"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var user User
+	if err := json.Unmarshal(rr.Body.Bytes(), &user); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if user.Name != "Jane Doe" || user.Email != "jane.doe@example.com" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+	
+	tests := []struct {
+		name string
+		id   string
+		body string
+		want int
+	}{
+		{"missing email", "2", `{"name":"Only Name"}`, http.StatusBadRequest},
+		{"invalid json", "2", `invalid json`, http.StatusBadRequest},
+		{"invalid id", "abc", `{"name":"A","email":"a@example.com"}`, http.StatusBadRequest},
+		{"not found", "999", `{"name":"A","email":"a@example.com"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := serveUser(store.handleUpdateUser, "PUT", tt.id, tt.body); rr.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandlePatchUser(t *testing.T) {
+	store := NewUserStore()
+	
+	rr := serveUser(store.handlePatchUser, "PATCH", "2", `{"email":"jane.new@example.com"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	user, _ := store.GetUser(2)
+	if user.Name != "Jane Smith" || user.Email != "jane.new@example.com" {
+		t.Errorf("Expected only the email to change, got %+v", user)
+	}
+	
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"no fields", `{}`, http.StatusBadRequest},
+		{"empty name", `{"name":""}`, http.StatusBadRequest},
+		{"invalid json", `invalid json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := serveUser(store.handlePatchUser, "PATCH", "2", tt.body); rr.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteUser(t *testing.T) {
+	store := NewUserStore()
+	
+	if rr := serveUser(store.handleDeleteUser, "DELETE", "1", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if rr := serveUser(store.handleGetUser, "GET", "1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected soft-deleted user to return %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	if _, exists := store.backend.Get(1); !exists {
+		t.Error("Expected soft delete to keep the record")
+	}
+	
+	req := httptest.NewRequest("DELETE", "/users/2?hard=true", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "2"})
+	rr := httptest.NewRecorder()
+	store.handleDeleteUser(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if _, exists := store.backend.Get(2); exists {
+		t.Error("Expected hard delete to remove the record")
+	}
+	
+	if rr := serveUser(store.handleDeleteUser, "DELETE", "999", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+	
+	req = httptest.NewRequest("DELETE", "/users/1?hard=maybe", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr = httptest.NewRecorder()
+	store.handleDeleteUser(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestSetUserStatus(t *testing.T) {
+	store := NewUserStore()
+	
+	if user, _ := store.GetUser(1); user.Status != UserStatusActive {
+		t.Errorf("Expected new users to be active, got %s", user.Status)
+	}
+	
+	tests := []struct {
+		to      string
+		wantErr error
+	}{
+		{UserStatusSuspended, nil},
+		{UserStatusInactive, ErrInvalidTransition},
+		{UserStatusActive, nil},
+		{UserStatusActive, ErrInvalidTransition},
+		{UserStatusInactive, nil},
+		{UserStatusSuspended, nil},
+	}
+	for _, tt := range tests {
+		_, err := store.SetUserStatus(1, tt.to)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("Moving to %s: expected %v, got %v", tt.to, tt.wantErr, err)
+		}
+	}
+	
+	if _, err := store.SetUserStatus(999, UserStatusActive); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestHandleUserTransition(t *testing.T) {
+	store := NewUserStore()
+	deactivate := store.handleUserTransition(UserStatusInactive, "/users/{id}/deactivate")
+	
+	rr := serveUser(deactivate, "POST", "2", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var user User
+	if err := json.Unmarshal(rr.Body.Bytes(), &user); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if user.Status != UserStatusInactive {
+		t.Errorf("Expected status %s, got %s", UserStatusInactive, user.Status)
+	}
+	
+	if rr := serveUser(deactivate, "POST", "2", ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d for repeated transition, got %d", http.StatusConflict, rr.Code)
+	}
+	if rr := serveUser(deactivate, "POST", "999", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHandleGetUsersHidesInactive(t *testing.T) {
+	store := NewUserStore()
+	if _, err := store.SetUserStatus(1, UserStatusInactive); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SetUserStatus(2, UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/users", 1},
+		{"/users?include_inactive=true", 2},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		store.handleGetUsers(rr, httptest.NewRequest("GET", tt.url, nil))
+		
+		var users []User
+		if err := json.Unmarshal(rr.Body.Bytes(), &users); err != nil {
+			t.Fatal("Failed to parse JSON response")
+		}
+		if len(users) != tt.want {
+			t.Errorf("%s: expected %d users, got %d", tt.url, tt.want, len(users))
+		}
+	}
+	
+	rr := httptest.NewRecorder()
+	store.handleGetUsers(rr, httptest.NewRequest("GET", "/users?include_inactive=perhaps", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestEmailUniqueness(t *testing.T) {
+	store := NewUserStore()
+	
+	_, err := store.CreateUser("John Again", "  JOHN@Example.com ")
+	var conflict *EmailConflictError
+	if !errors.As(err, &conflict) || conflict.ExistingID != 1 {
+		t.Fatalf("Expected conflict with user 1, got %v", err)
+	}
+	
+	if _, err := store.CreateUser("Bad", "not-an-email"); !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("Expected ErrInvalidEmail, got %v", err)
+	}
+	
+	user, err := store.CreateUser("Mixed Case", "Mixed.Case@Example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "mixed.case@example.com" {
+		t.Errorf("Expected normalized email, got %s", user.Email)
+	}
+	
+	jane := "jane@example.com"
+	if _, err := store.PatchUser(user.ID, nil, &jane); !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("Expected ErrDuplicateEmail on update, got %v", err)
+	}
+	// Re-saving a user's own address is not a conflict
+	if _, err := store.UpdateUser(2, "Jane", "Jane@example.com"); err != nil {
+		t.Errorf("Expected user to keep its own email, got %v", err)
+	}
+	
+	// Changing or deleting frees the previous address
+	renamed := "john.doe@example.com"
+	if _, err := store.PatchUser(1, nil, &renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateUser("New John", "john@example.com"); err != nil {
+		t.Errorf("Expected old address to be free, got %v", err)
+	}
+	if err := store.DeleteUser(2, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateUser("New Jane", "jane@example.com"); err != nil {
+		t.Errorf("Expected deleted user's address to be free, got %v", err)
+	}
+}
+
+func TestHandleCreateUserDuplicateEmail(t *testing.T) {
+	store := NewUserStore()
+	
+	rr := httptest.NewRecorder()
+	store.handleCreateUser(rr, httptest.NewRequest("POST", "/users", bytes.NewBufferString(`{"name":"Dup","email":"Jane@Example.com"}`)))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+	}
+	var resp struct {
+		Code           string `json:"code"`
+		ExistingUserID int    `json:"existing_user_id"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if resp.ExistingUserID != 2 {
+		t.Errorf("Expected existing user ID 2, got %d", resp.ExistingUserID)
+	}
+	if resp.Code != string(httpx.CodeConflict) {
+		t.Errorf("Expected code conflict, got %q", resp.Code)
+	}
+	
+	rr = httptest.NewRecorder()
+	store.handleCreateUser(rr, httptest.NewRequest("POST", "/users", bytes.NewBufferString(`{"name":"Bad","email":"bad@"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	
+	if rr := serveUser(store.handlePatchUser, "PATCH", "1", `{"email":"jane@example.com"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status code %d on patch, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestHandleGetUsersPagination(t *testing.T) {
+	store := NewUserStore()
+	
+	rr := httptest.NewRecorder()
+	store.handleGetUsers(rr, httptest.NewRequest("GET", "/users?page_size=1&sort_by=name", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "2" {
+		t.Errorf("Expected X-Total-Count 2, got %q", total)
+	}
+	token := rr.Header().Get("X-Next-Page-Token")
+	if token == "" {
+		t.Fatal("Expected a next page token")
+	}
+	var users []User
+	if err := json.Unmarshal(rr.Body.Bytes(), &users); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if len(users) != 1 || users[0].Name != "Jane Smith" {
+		t.Errorf("Expected Jane Smith first, got %+v", users)
+	}
+	
+	rr = httptest.NewRecorder()
+	store.handleGetUsers(rr, httptest.NewRequest("GET", "/users?page_size=1&sort_by=name&page_token="+token, nil))
+	users = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &users); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if len(users) != 1 || users[0].Name != "John Doe" || rr.Header().Get("X-Next-Page-Token") != "" {
+		t.Errorf("Expected John Doe on the last page, got %+v", users)
+	}
+	
+	for _, url := range []string{"/users?sort_by=age", "/users?page_token=bogus", "/users?page_size=x"} {
+		rr := httptest.NewRecorder()
+		store.handleGetUsers(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", url, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
 func TestAuthorHandler(t *testing.T) {
 	req, err := http.NewRequest("GET", "/author", nil)
 	if err != nil {
@@ -269,31 +667,119 @@ func TestAuthorHandler(t *testing.T) {
 func TestCORSPreflight(t *testing.T) {
 	store := NewUserStore()
 	r := mux.NewRouter()
-	r.HandleFunc("/users", store.handleGetUsers).Methods("GET", "OPTIONS") // tambahkan OPTIONS agar middleware dijalankan
-	// Tambahkan CORS middleware
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	})
+	r.HandleFunc("/users", store.handleGetUsers).Methods("GET")
+	r.HandleFunc("/users", store.handleCreateUser).Methods("POST")
+	handler := httpx.CORSMiddleware(defaultCORSConfig, r)(r)
 
 	req, err := http.NewRequest("OPTIONS", "/users", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("Origin", "https://shop.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type, Idempotency-Key")
 	rr := httptest.NewRecorder()
-	r.ServeHTTP(rr, req)
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, status)
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, status)
 	}
 	if rr.Header().Get("Access-Control-Allow-Origin") != "*" {
 		t.Errorf("CORS header missing")
 	}
-} 
\ No newline at end of file
+	if methods := rr.Header().Get("Access-Control-Allow-Methods"); methods != "GET, POST" {
+		t.Errorf("Expected the methods of /users, got %q", methods)
+	}
+	if headers := rr.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(headers, httpx.IdempotencyKeyHeader) {
+		t.Errorf("Expected Idempotency-Key to be allowed, got %q", headers)
+	}
+} 
+func TestHandleGetUserMetrics(t *testing.T) {
+	store := NewUserStore()
+	
+	rr := httptest.NewRecorder()
+	store.handleGetUserMetrics(rr, httptest.NewRequest("GET", "/users/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	
+	var metrics UserMetrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.TotalUsers != 2 || metrics.NewUsersToday != 2 || len(metrics.DailyStats) < 30 {
+		t.Errorf("Expected both users and 30 days of stats, got %+v", metrics)
+	}
+	if last := metrics.DailyStats[len(metrics.DailyStats)-1]; last.NewUsers != 2 || last.ActiveUsers != 2 {
+		t.Errorf("Expected both users to be created today, got %+v", last)
+	}
+	
+	for _, query := range []string{"start=bogus", "start=2025-01-02T00:00:00Z&end=2025-01-01T00:00:00Z"} {
+		rr := httptest.NewRecorder()
+		store.handleGetUserMetrics(rr, httptest.NewRequest("GET", "/users/metrics?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHandleCreateUserIdempotent(t *testing.T) {
+	store := NewUserStore()
+	handler := httpx.NewIdempotency(time.Hour).Middleware(http.HandlerFunc(store.handleCreateUser))
+	before := len(store.backend.List())
+	
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(httpx.IdempotencyKeyHeader, "user-key-1")
+		req.Header.Set("Authorization", "Bearer client-1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	
+	body := `{"name": "Retry User", "email": "retry@example.com"}`
+	first := create(body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, first.Code)
+	}
+	retry := create(body)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first response to be replayed, got %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(httpx.IdempotentReplayedHeader) != "true" {
+		t.Error("Expected the replayed response to be marked")
+	}
+	if after := len(store.backend.List()); after != before+1 {
+		t.Errorf("Expected one user to be created, got %d new users", after-before)
+	}
+	
+	if rr := create(`{"name": "Other User", "email": "other@example.com"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d for a different body, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestHandleCreateUserProblemDetails(t *testing.T) {
+	store := NewUserStore()
+	
+	req := httptest.NewRequest("POST", "/users", bytes.NewBufferString(`{"name":"","email":""}`))
+	req.Header.Set(httpx.RequestIDHeader, "req-1")
+	rr := httptest.NewRecorder()
+	store.handleCreateUser(rr, req)
+	
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != httpx.ProblemContentType {
+		t.Errorf("Expected Content-Type %s, got %s", httpx.ProblemContentType, ct)
+	}
+	var problem httpx.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal("Failed to parse JSON response")
+	}
+	if problem.Code != httpx.CodeValidationFailed || problem.RequestID != "req-1" || problem.Instance != "/users" {
+		t.Errorf("Unexpected problem: %+v", problem)
+	}
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "name" || problem.Errors[1].Field != "email" {
+		t.Errorf("Expected name and email field errors, got %+v", problem.Errors)
+	}
+}
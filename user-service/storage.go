@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Supported values for USER_STORE_BACKEND
+const (
+	BackendMemory = "memory"
+	BackendFile   = "file"
+)
+
+// UserBackend persists users on behalf of UserStore. Implementations must be
+// safe for concurrent use and must return copies so callers cannot mutate
+// stored users.
+type UserBackend interface {
+	// Create assigns the next free ID to user and persists it
+	Create(user *User) error
+	// Get retrieves a user by ID
+	Get(id int) (*User, bool)
+	// List retrieves all users
+	List() []*User
+	// Update replaces a stored user
+	Update(user *User) error
+	// Delete permanently removes a user
+	Delete(id int) error
+	// Ping fails when the backend can no longer persist users
+	Ping() error
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// ErrUserNotFound is returned when a user does not exist
+var ErrUserNotFound = errors.New("user not found")
+
+// NewUserBackend creates the backend named by kind
+func NewUserBackend(kind, path string) (UserBackend, error) {
+	switch kind {
+	case "", BackendMemory:
+		return NewMemoryBackend(), nil
+	case BackendFile:
+		return OpenFileBackend(path)
+	default:
+		return nil, fmt.Errorf("unknown user store backend %q", kind)
+	}
+}
+
+// MemoryBackend keeps users in a map and loses them on restart
+type MemoryBackend struct {
+	users  map[int]*User
+	mutex  sync.RWMutex
+	nextID int
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		users:  make(map[int]*User),
+		nextID: 1,
+	}
+}
+
+// Create assigns the next free ID to user and stores it
+func (b *MemoryBackend) Create(user *User) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	user.ID = b.nextID
+	b.nextID++
+
+	stored := *user
+	b.users[user.ID] = &stored
+
+	return nil
+}
+
+// Get retrieves a user by ID
+func (b *MemoryBackend) Get(id int) (*User, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	user, exists := b.users[id]
+	if !exists {
+		return nil, false
+	}
+	copied := *user
+	return &copied, true
+}
+
+// List retrieves all users
+func (b *MemoryBackend) List() []*User {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	users := make([]*User, 0, len(b.users))
+	for _, user := range b.users {
+		copied := *user
+		users = append(users, &copied)
+	}
+
+	return users
+}
+
+// Update replaces a stored user
+func (b *MemoryBackend) Update(user *User) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, exists := b.users[user.ID]; !exists {
+		return ErrUserNotFound
+	}
+	stored := *user
+	b.users[user.ID] = &stored
+
+	return nil
+}
+
+// Delete permanently removes a user
+func (b *MemoryBackend) Delete(id int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, exists := b.users[id]; !exists {
+		return ErrUserNotFound
+	}
+	delete(b.users, id)
+
+	return nil
+}
+
+// Ping always succeeds for the in-memory backend
+func (b *MemoryBackend) Ping() error {
+	return nil
+}
+
+// Close is a no-op for the in-memory backend
+func (b *MemoryBackend) Close() error {
+	return nil
+}
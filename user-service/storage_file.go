@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Operations recorded in the user log
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// logRecord is a single line of the append-only user log
+type logRecord struct {
+	Op   string `json:"op"`
+	ID   int    `json:"id,omitempty"`
+	User *User  `json:"user,omitempty"`
+}
+
+// logFile is the part of *os.File used by FileBackend
+type logFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// FileBackend keeps users in memory and appends every change to a log file
+// that is replayed on startup
+type FileBackend struct {
+	*MemoryBackend
+	file logFile
+}
+
+// OpenFileBackend opens or creates the user log at path and replays it
+func OpenFileBackend(path string) (*FileBackend, error) {
+	if path == "" {
+		return nil, errors.New("user store path is required for the file backend")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create user store directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open user store: %w", err)
+	}
+
+	b := &FileBackend{MemoryBackend: NewMemoryBackend(), file: file}
+	if err := b.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// replay rebuilds the in-memory state from the log. A torn final record left
+// by a crash is discarded and truncated away.
+func (b *FileBackend) replay() error {
+	reader := bufio.NewReader(b.file)
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				log.Printf("Discarding incomplete record at end of user store (offset %d)", offset)
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read user store: %w", err)
+		}
+
+		var record logRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("corrupt user store record at offset %d: %w", offset, err)
+		}
+		b.apply(record)
+		offset += int64(len(line))
+	}
+
+	if err := b.file.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate user store: %w", err)
+	}
+	_, err := b.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// apply updates the in-memory state with a replayed record
+func (b *FileBackend) apply(record logRecord) {
+	id := record.ID
+	if record.User != nil {
+		id = record.User.ID
+	}
+	// Deleted IDs are never reused
+	if id >= b.nextID {
+		b.nextID = id + 1
+	}
+
+	switch record.Op {
+	case opPut:
+		if record.User != nil {
+			b.users[id] = record.User
+		}
+	case opDelete:
+		delete(b.users, id)
+	}
+}
+
+// append writes a record to the log and flushes it to disk. A failed write
+// is cut off the log so that later records do not follow a partial one.
+func (b *FileBackend) append(record logRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	offset, err := b.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("seek user store: %w", err)
+	}
+	if _, err := b.file.Write(append(data, '\n')); err != nil {
+		b.rollback(offset)
+		return fmt.Errorf("write user store: %w", err)
+	}
+	if err := b.file.Sync(); err != nil {
+		b.rollback(offset)
+		return fmt.Errorf("sync user store: %w", err)
+	}
+	return nil
+}
+
+// rollback truncates the log back to offset
+func (b *FileBackend) rollback(offset int64) {
+	if err := b.file.Truncate(offset); err != nil {
+		log.Printf("Failed to truncate user store after a failed write: %v", err)
+		return
+	}
+	if _, err := b.file.Seek(offset, io.SeekStart); err != nil {
+		log.Printf("Failed to seek user store after a failed write: %v", err)
+	}
+}
+
+// Create assigns the next free ID to user and persists it
+func (b *FileBackend) Create(user *User) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	stored := *user
+	stored.ID = b.nextID
+	if err := b.append(logRecord{Op: opPut, User: &stored}); err != nil {
+		return err
+	}
+
+	b.nextID++
+	b.users[stored.ID] = &stored
+	user.ID = stored.ID
+
+	return nil
+}
+
+// Update replaces a stored user
+func (b *FileBackend) Update(user *User) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, exists := b.users[user.ID]; !exists {
+		return ErrUserNotFound
+	}
+	stored := *user
+	if err := b.append(logRecord{Op: opPut, User: &stored}); err != nil {
+		return err
+	}
+	b.users[user.ID] = &stored
+
+	return nil
+}
+
+// Delete permanently removes a user
+func (b *FileBackend) Delete(id int) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, exists := b.users[id]; !exists {
+		return ErrUserNotFound
+	}
+	if err := b.append(logRecord{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+	delete(b.users, id)
+
+	return nil
+}
+
+// Ping fails when the log file can no longer be written, such as when it was
+// removed or replaced on disk
+func (b *FileBackend) Ping() error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	open, err := b.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat user store: %w", err)
+	}
+	onDisk, err := os.Stat(b.file.Name())
+	if err != nil {
+		return fmt.Errorf("stat user store: %w", err)
+	}
+	if !os.SameFile(open, onDisk) {
+		return errors.New("user store file was replaced")
+	}
+	return nil
+}
+
+// Close closes the log file
+func (b *FileBackend) Close() error {
+	return b.file.Close()
+}
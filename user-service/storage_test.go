@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewUserBackend(t *testing.T) {
+	if _, err := NewUserBackend(BackendMemory, ""); err != nil {
+		t.Errorf("Expected memory backend, got error %v", err)
+	}
+	if _, err := NewUserBackend("bogus", ""); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+	if _, err := NewUserBackend(BackendFile, ""); err == nil {
+		t.Error("Expected error for file backend without path")
+	}
+}
+
+func TestMemoryBackendReturnsCopies(t *testing.T) {
+	backend := NewMemoryBackend()
+	user := &User{Name: "Copy", Email: "copy@example.com"}
+	if err := backend.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	user.Name = "Changed"
+	got, _ := backend.Get(user.ID)
+	if got.Name != "Copy" {
+		t.Errorf("Expected stored user to be unaffected, got %s", got.Name)
+	}
+}
+
+func TestFileBackendSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+
+	backend, err := OpenFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewUserStoreWithBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := store.CreateUser("Persisted", "persisted@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+
+	backend, err = OpenFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	store, err = NewUserStoreWithBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if users := store.GetAllUsers(); len(users) != 3 {
+		t.Errorf("Expected 3 users after restart without reseeding, got %d", len(users))
+	}
+	user, exists := store.GetUser(created.ID)
+	if !exists || user.Email != "persisted@example.com" {
+		t.Errorf("Expected persisted user, got %+v", user)
+	}
+
+	next, err := store.CreateUser("After Restart", "after@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != created.ID+1 {
+		t.Errorf("Expected ID %d after restart, got %d", created.ID+1, next.ID)
+	}
+}
+
+func TestFileBackendReplaysUpdatesAndDeletes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+
+	backend, err := OpenFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewUserStoreWithBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra, err := store.CreateUser("Extra", "extra@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpdateUser(1, "John Renamed", "john@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteUser(2, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteUser(extra.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+
+	backend, err = OpenFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	store, err = NewUserStoreWithBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user, _ := store.GetUser(1); user == nil || user.Name != "John Renamed" {
+		t.Errorf("Expected update to survive restart, got %+v", user)
+	}
+	if _, exists := store.GetUser(2); exists {
+		t.Error("Expected soft delete to survive restart")
+	}
+	if _, exists := backend.Get(extra.ID); exists {
+		t.Error("Expected hard delete to survive restart")
+	}
+
+	// IDs of hard-deleted users are never handed out again
+	next, err := store.CreateUser("Next", "next@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != extra.ID+1 {
+		t.Errorf("Expected ID %d, got %d", extra.ID+1, next.ID)
+	}
+}
+
+func TestFileBackendDiscardsTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+
+	backend, err := OpenFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Create(&User{Name: "Whole", Email: "whole@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"put","user":{"id":2,"na`)
+	f.Close()
+
+	backend, err = OpenFileBackend(path)
+	if err != nil {
+		t.Fatalf("Expected recovery from torn write, got %v", err)
+	}
+	if users := backend.List(); len(users) != 1 {
+		t.Errorf("Expected 1 user after recovery, got %d", len(users))
+	}
+
+	user := &User{Name: "Next", Email: "next@example.com"}
+	if err := backend.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+	if user.ID != 2 {
+		t.Errorf("Expected ID 2, got %d", user.ID)
+	}
+
+	backend, err = OpenFileBackend(path)
+	if err != nil {
+		t.Fatalf("Expected clean log after recovery, got %v", err)
+	}
+	defer backend.Close()
+	if users := backend.List(); len(users) != 2 {
+		t.Errorf("Expected 2 users, got %d", len(users))
+	}
+}
+
+func TestFileBackendRejectsCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+	if err := os.WriteFile(path, []byte("not json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenFileBackend(path); err == nil {
+		t.Error("Expected error for corrupt record")
+	}
+}
+
+// shortWriteFile writes half of each buffer and then fails
+type shortWriteFile struct {
+	*os.File
+}
+
+func (f shortWriteFile) Write(p []byte) (int, error) {
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, errors.New("disk full")
+}
+
+func TestFileBackendRollsBackFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.log")
+
+	backend, err := OpenFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := backend.file.(*os.File)
+	backend.file = shortWriteFile{file}
+	if err := backend.Create(&User{Name: "Lost", Email: "lost@example.com"}); err == nil {
+		t.Fatal("Expected the failed write to be reported")
+	}
+	backend.file = file
+	if err := backend.Create(&User{Name: "Kept", Email: "kept@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+
+	backend, err = OpenFileBackend(path)
+	if err != nil {
+		t.Fatalf("Expected the log to replay after a failed write, got %v", err)
+	}
+	defer backend.Close()
+	if users := backend.List(); len(users) != 1 || users[0].Name != "Kept" {
+		t.Errorf("Expected only the kept user, got %+v", users)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxMetricsDays caps the number of days in one daily breakdown
+const maxMetricsDays = 366
+
+// defaultMetricsRange is covered by requests that give no start
+const defaultMetricsRange = 30 * 24 * time.Hour
+
+// UserMetrics counts the users that have not been deleted. The new user
+// counts cover the last 24 hours, 7 days and 30 days.
+type UserMetrics struct {
+	TotalUsers    int `json:"total_users"`
+	ActiveUsers   int `json:"active_users"`
+	NewUsersToday int `json:"new_users_today"`
+	NewUsersWeek  int `json:"new_users_week"`
+	NewUsersMonth int `json:"new_users_month"`
+
+	// Start, End and DailyStats describe the requested range, if any
+	Start      string           `json:"start,omitempty"`
+	End        string           `json:"end,omitempty"`
+	DailyStats []DailyUserStats `json:"daily_stats,omitempty"`
+}
+
+// DailyUserStats counts the users created and soft-deleted on one UTC day.
+// ActiveUsers is the number of users that existed at the end of the day;
+// status changes are not recorded, so it includes inactive and suspended
+// users. Hard-deleted users leave no record and are not counted.
+type DailyUserStats struct {
+	Date         string `json:"date"`
+	NewUsers     int    `json:"new_users"`
+	ActiveUsers  int    `json:"active_users"`
+	DeletedUsers int    `json:"deleted_users"`
+}
+
+// ParseUserMetricsRange reads start and end from URL query values. The range
+// defaults to the 30 days up to now.
+func ParseUserMetricsRange(values url.Values, now time.Time) (start, end time.Time, err error) {
+	if start, err = parseTimeParam(values.Get("start")); err != nil {
+		return start, end, fmt.Errorf("%w: start %v", ErrInvalidQuery, err)
+	}
+	if end, err = parseTimeParam(values.Get("end")); err != nil {
+		return start, end, fmt.Errorf("%w: end %v", ErrInvalidQuery, err)
+	}
+	if end.IsZero() {
+		end = now
+	}
+	if start.IsZero() {
+		start = end.Add(-defaultMetricsRange)
+	}
+	return start, end, nil
+}
+
+// UserMetrics reports the user counts as of now, with a daily breakdown of
+// the UTC days overlapping [start, end) unless start is zero
+func (s *UserStore) UserMetrics(start, end, now time.Time) (*UserMetrics, error) {
+	days, err := metricsDays(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	users := s.backend.List()
+	s.mutex.RUnlock()
+
+	metrics := &UserMetrics{}
+	for _, user := range users {
+		if user.Deleted != "" {
+			continue
+		}
+		metrics.TotalUsers++
+		if withDefaultStatus(user).Status == UserStatusActive {
+			metrics.ActiveUsers++
+		}
+		created, err := time.Parse(time.RFC3339, user.Created)
+		if err != nil {
+			continue
+		}
+		age := now.Sub(created)
+		if age < 24*time.Hour {
+			metrics.NewUsersToday++
+		}
+		if age < 7*24*time.Hour {
+			metrics.NewUsersWeek++
+		}
+		if age < 30*24*time.Hour {
+			metrics.NewUsersMonth++
+		}
+	}
+	if len(days) == 0 {
+		return metrics, nil
+	}
+
+	metrics.Start = start.UTC().Format(time.RFC3339)
+	metrics.End = end.UTC().Format(time.RFC3339)
+	metrics.DailyStats = make([]DailyUserStats, 0, len(days))
+	for _, day := range days {
+		next := day.AddDate(0, 0, 1)
+		stats := DailyUserStats{Date: day.Format("2006-01-02")}
+		for _, user := range users {
+			created, err := time.Parse(time.RFC3339, user.Created)
+			if err != nil {
+				continue
+			}
+			// Users that were never deleted have a zero deletion time
+			deleted, _ := time.Parse(time.RFC3339, user.Deleted)
+			if !created.Before(day) && created.Before(next) {
+				stats.NewUsers++
+			}
+			if !deleted.IsZero() && !deleted.Before(day) && deleted.Before(next) {
+				stats.DeletedUsers++
+			}
+			if created.Before(next) && (deleted.IsZero() || !deleted.Before(next)) {
+				stats.ActiveUsers++
+			}
+		}
+		metrics.DailyStats = append(metrics.DailyStats, stats)
+	}
+	return metrics, nil
+}
+
+// metricsDays returns the start of each UTC day overlapping [start, end), or
+// nothing when start is zero
+func metricsDays(start, end time.Time) ([]time.Time, error) {
+	if start.IsZero() {
+		return nil, nil
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: start must be before end", ErrInvalidQuery)
+	}
+
+	start = start.UTC()
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	var days []time.Time
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if len(days) == maxMetricsDays {
+			return nil, fmt.Errorf("%w: at most %d days are allowed", ErrInvalidQuery, maxMetricsDays)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// Descriptions of the user gauges
+var (
+	usersTotalDesc = prometheus.NewDesc(
+		"users",
+		"Number of users that have not been deleted",
+		nil, nil,
+	)
+	usersActiveDesc = prometheus.NewDesc(
+		"users_active",
+		"Number of users in the active status",
+		nil, nil,
+	)
+	usersNewDesc = prometheus.NewDesc(
+		"users_new",
+		"Number of users created within the window up to now",
+		[]string{"window"}, nil,
+	)
+)
+
+// userMetricsCollector reports the counts of UserMetrics from the store at
+// scrape time, so the gauges always agree with GET /users/metrics
+type userMetricsCollector struct {
+	store *UserStore
+}
+
+// NewUserMetricsCollector creates a collector exposing the user gauges for store
+func NewUserMetricsCollector(store *UserStore) prometheus.Collector {
+	return &userMetricsCollector{store: store}
+}
+
+// Describe implements prometheus.Collector
+func (c *userMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- usersTotalDesc
+	ch <- usersActiveDesc
+	ch <- usersNewDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *userMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	metrics, err := c.store.UserMetrics(time.Time{}, time.Time{}, time.Now())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(usersTotalDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(usersTotalDesc, prometheus.GaugeValue, float64(metrics.TotalUsers))
+	ch <- prometheus.MustNewConstMetric(usersActiveDesc, prometheus.GaugeValue, float64(metrics.ActiveUsers))
+	ch <- prometheus.MustNewConstMetric(usersNewDesc, prometheus.GaugeValue, float64(metrics.NewUsersToday), "day")
+	ch <- prometheus.MustNewConstMetric(usersNewDesc, prometheus.GaugeValue, float64(metrics.NewUsersWeek), "week")
+	ch <- prometheus.MustNewConstMetric(usersNewDesc, prometheus.GaugeValue, float64(metrics.NewUsersMonth), "month")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newMetricsTestStore returns a store with users created and deleted on the
+// first days of January 2025
+func newMetricsTestStore(t *testing.T) *UserStore {
+	t.Helper()
+	backend := NewMemoryBackend()
+	day := func(d, hour int) string {
+		return time.Date(2025, 1, d, hour, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	}
+	for _, user := range []User{
+		{Name: "Ann", Email: "ann@example.com", Status: UserStatusActive, Created: day(1, 9)},
+		{Name: "Bob", Email: "bob@example.com", Status: UserStatusSuspended, Created: day(1, 18)},
+		{Name: "Cid", Email: "cid@example.com", Status: UserStatusActive, Created: day(2, 9), Deleted: day(3, 12)},
+		{Name: "Dee", Email: "dee@example.com", Status: UserStatusInactive, Created: day(3, 9)},
+	} {
+		user := user
+		if err := backend.Create(&user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := NewUserStoreWithBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestUserMetrics(t *testing.T) {
+	store := newMetricsTestStore(t)
+	now := time.Date(2025, 1, 3, 18, 0, 0, 0, time.UTC)
+
+	metrics, err := store.UserMetrics(time.Time{}, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.TotalUsers != 3 || metrics.ActiveUsers != 1 || metrics.NewUsersToday != 1 || metrics.NewUsersWeek != 3 || metrics.DailyStats != nil {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+
+	metrics, err = store.UserMetrics(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DailyUserStats{
+		{Date: "2025-01-01", NewUsers: 2, ActiveUsers: 2},
+		{Date: "2025-01-02", NewUsers: 1, ActiveUsers: 3},
+		{Date: "2025-01-03", NewUsers: 1, ActiveUsers: 3, DeletedUsers: 1},
+		{Date: "2025-01-04", ActiveUsers: 3},
+	}
+	if !reflect.DeepEqual(metrics.DailyStats, want) {
+		t.Errorf("Expected daily stats %+v, got %+v", want, metrics.DailyStats)
+	}
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, r := range [][2]time.Time{{base, base}, {base, base.AddDate(2, 0, 0)}} {
+		if _, err := store.UserMetrics(r[0], r[1], now); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected ErrInvalidQuery for %v, got %v", r, err)
+		}
+	}
+}
+
+func TestParseUserMetricsRange(t *testing.T) {
+	now := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	start, end, err := ParseUserMetricsRange(url.Values{}, now)
+	if err != nil || !end.Equal(now) || !start.Equal(now.Add(-defaultMetricsRange)) {
+		t.Errorf("Expected the last 30 days, got %v to %v, %v", start, end, err)
+	}
+	start, end, err = ParseUserMetricsRange(url.Values{"start": {"2025-01-01T00:00:00Z"}, "end": {"1735776000"}}, now)
+	if err != nil || start.Unix() != 1735689600 || end.Unix() != 1735776000 {
+		t.Errorf("Unexpected range %v to %v, %v", start, end, err)
+	}
+	if _, _, err := ParseUserMetricsRange(url.Values{"end": {"tomorrow"}}, now); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for a malformed end, got %v", err)
+	}
+}
+
+func TestUserMetricsCollector(t *testing.T) {
+	store := NewUserStore()
+	if _, err := store.SetUserStatus(2, UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP users Number of users that have not been deleted
+# TYPE users gauge
+users 2
+# HELP users_active Number of users in the active status
+# TYPE users_active gauge
+users_active 1
+# HELP users_new Number of users created within the window up to now
+# TYPE users_new gauge
+users_new{window="day"} 2
+users_new{window="month"} 2
+users_new{window="week"} 2
+`
+	if err := testutil.CollectAndCompare(NewUserMetricsCollector(store), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page size limits for user listings
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Fields users can be sorted by
+const (
+	SortByID      = "id"
+	SortByName    = "name"
+	SortByEmail   = "email"
+	SortByCreated = "created"
+)
+
+// Sort directions
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// ErrInvalidQuery is returned when list parameters are malformed
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ErrInvalidPageToken is returned when a page token is malformed or was issued
+// for a different query
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// UserQuery selects, orders and pages a list of users
+type UserQuery struct {
+	PageSize  int
+	PageToken string
+
+	// Status limits results to one status. When empty, inactive users are
+	// hidden unless IncludeInactive is set.
+	Status          string
+	IncludeInactive bool
+	EmailDomain     string
+	CreatedAfter    time.Time
+	CreatedBefore   time.Time
+	SearchQuery     string
+
+	SortBy    string
+	SortOrder string
+}
+
+// UserPage is one page of a user listing
+type UserPage struct {
+	Users         []*User
+	NextPageToken string
+	TotalCount    int
+}
+
+// pageCursor is the decoded form of a page token. It records the position of
+// the last user returned so that later pages are stable under inserts.
+type pageCursor struct {
+	Query string `json:"q"`
+	ID    int    `json:"id"`
+	Key   string `json:"k"`
+}
+
+// ParseUserQuery reads a UserQuery from URL query parameters
+func ParseUserQuery(values url.Values) (UserQuery, error) {
+	q := UserQuery{
+		PageToken:   values.Get("page_token"),
+		Status:      values.Get("status"),
+		EmailDomain: values.Get("email_domain"),
+		SearchQuery: values.Get("search_query"),
+		SortBy:      values.Get("sort_by"),
+		SortOrder:   values.Get("sort_order"),
+	}
+
+	if value := values.Get("page_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return q, fmt.Errorf("%w: page_size must be an integer", ErrInvalidQuery)
+		}
+		q.PageSize = size
+	}
+	if value := values.Get("include_inactive"); value != "" {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			return q, fmt.Errorf("%w: include_inactive must be a boolean", ErrInvalidQuery)
+		}
+		q.IncludeInactive = include
+	}
+
+	var err error
+	if q.CreatedAfter, err = parseTimeParam(values.Get("created_after")); err != nil {
+		return q, fmt.Errorf("%w: created_after %v", ErrInvalidQuery, err)
+	}
+	if q.CreatedBefore, err = parseTimeParam(values.Get("created_before")); err != nil {
+		return q, fmt.Errorf("%w: created_before %v", ErrInvalidQuery, err)
+	}
+
+	return q, nil
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or Unix seconds
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("must be RFC 3339 or Unix seconds")
+	}
+	return t, nil
+}
+
+// normalize applies defaults and validates the query
+func (q *UserQuery) normalize() error {
+	switch {
+	case q.PageSize < 0:
+		return fmt.Errorf("%w: page_size must not be negative", ErrInvalidQuery)
+	case q.PageSize == 0:
+		q.PageSize = defaultPageSize
+	case q.PageSize > maxPageSize:
+		q.PageSize = maxPageSize
+	}
+
+	if q.Status != "" {
+		if _, known := userTransitions[q.Status]; !known {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidQuery, q.Status)
+		}
+	}
+
+	q.SortBy = strings.ToLower(q.SortBy)
+	switch q.SortBy {
+	case "":
+		q.SortBy = SortByID
+	case SortByID, SortByName, SortByEmail, SortByCreated:
+	default:
+		return fmt.Errorf("%w: unknown sort_by %q", ErrInvalidQuery, q.SortBy)
+	}
+
+	q.SortOrder = strings.ToLower(q.SortOrder)
+	switch q.SortOrder {
+	case "":
+		q.SortOrder = SortAsc
+	case SortAsc, SortDesc:
+	default:
+		return fmt.Errorf("%w: unknown sort_order %q", ErrInvalidQuery, q.SortOrder)
+	}
+
+	q.EmailDomain = strings.ToLower(strings.TrimPrefix(q.EmailDomain, "@"))
+	q.SearchQuery = strings.ToLower(strings.TrimSpace(q.SearchQuery))
+	return nil
+}
+
+// matches reports whether user passes the query filters
+func (q *UserQuery) matches(user *User) bool {
+	if q.Status != "" {
+		if user.Status != q.Status {
+			return false
+		}
+	} else if !q.IncludeInactive && user.Status == UserStatusInactive {
+		return false
+	}
+
+	email := strings.ToLower(user.Email)
+	if q.EmailDomain != "" && !strings.HasSuffix(email, "@"+q.EmailDomain) {
+		return false
+	}
+	if q.SearchQuery != "" && !strings.Contains(strings.ToLower(user.Name), q.SearchQuery) && !strings.Contains(email, q.SearchQuery) {
+		return false
+	}
+
+	if !q.CreatedAfter.IsZero() || !q.CreatedBefore.IsZero() {
+		created, err := time.Parse(time.RFC3339, user.Created)
+		if err != nil {
+			return false
+		}
+		if !q.CreatedAfter.IsZero() && !created.After(q.CreatedAfter) {
+			return false
+		}
+		if !q.CreatedBefore.IsZero() && !created.Before(q.CreatedBefore) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fingerprint identifies the filters and ordering a page token belongs to
+func (q *UserQuery) fingerprint() string {
+	parts := []string{
+		q.Status,
+		strconv.FormatBool(q.IncludeInactive),
+		q.EmailDomain,
+		q.SearchQuery,
+		strconv.FormatInt(unixOrZero(q.CreatedAfter), 10),
+		strconv.FormatInt(unixOrZero(q.CreatedBefore), 10),
+		q.SortBy,
+		q.SortOrder,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// sortKey returns the value user is ordered by for field
+func sortKey(user *User, field string) string {
+	switch field {
+	case SortByName:
+		return strings.ToLower(user.Name)
+	case SortByEmail:
+		return strings.ToLower(user.Email)
+	case SortByCreated:
+		if created, err := time.Parse(time.RFC3339, user.Created); err == nil {
+			// Fixed-width UTC timestamps sort correctly as strings
+			return created.UTC().Format("20060102150405")
+		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+// compareUsers orders users by key and then by ID so that ties are stable
+func compareUsers(aKey string, aID int, bKey string, bID int) int {
+	switch {
+	case aKey < bKey:
+		return -1
+	case aKey > bKey:
+		return 1
+	case aID < bID:
+		return -1
+	case aID > bID:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ListUsers returns the page of users selected by q
+func (s *UserStore) ListUsers(q UserQuery) (*UserPage, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	fingerprint := q.fingerprint()
+
+	var after *pageCursor
+	if q.PageToken != "" {
+		cursor, err := decodePageToken(q.PageToken)
+		if err != nil || cursor.Query != fingerprint {
+			return nil, ErrInvalidPageToken
+		}
+		after = cursor
+	}
+
+	// direction flips comparisons for descending order
+	direction := 1
+	if q.SortOrder == SortDesc {
+		direction = -1
+	}
+
+	matched := make([]*User, 0)
+	for _, user := range s.GetAllUsers() {
+		if q.matches(user) {
+			matched = append(matched, user)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		return direction*compareUsers(sortKey(a, q.SortBy), a.ID, sortKey(b, q.SortBy), b.ID) < 0
+	})
+
+	page := &UserPage{TotalCount: len(matched), Users: make([]*User, 0, q.PageSize)}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			user := matched[i]
+			return direction*compareUsers(sortKey(user, q.SortBy), user.ID, after.Key, after.ID) > 0
+		})
+	}
+
+	end := start + q.PageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+	page.Users = append(page.Users, matched[start:end]...)
+
+	if end < len(matched) {
+		last := matched[end-1]
+		page.NextPageToken = encodePageToken(&pageCursor{
+			Query: fingerprint,
+			ID:    last.ID,
+			Key:   sortKey(last, q.SortBy),
+		})
+	}
+
+	return page, nil
+}
+
+// encodePageToken turns a cursor into an opaque token
+func encodePageToken(cursor *pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken parses a token produced by encodePageToken
+func decodePageToken(token string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
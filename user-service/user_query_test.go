@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newQueryTestStore returns a store with users created a day apart
+func newQueryTestStore(t *testing.T) *UserStore {
+	t.Helper()
+	store, err := NewUserStoreWithBackend(NewMemoryBackend())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"Carol", "alice", "Bob", "dave", "Eve"}
+	domains := []string{"example.com", "corp.io", "example.com", "corp.io", "example.com"}
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range names {
+		user, err := store.CreateUser(name, fmt.Sprintf("%s@%s", name, domains[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.Created = base.AddDate(0, 0, i).Format(time.RFC3339)
+		if err := store.backend.Update(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func userIDs(users []*User) []int {
+	ids := make([]int, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func TestListUsersPagesAreStable(t *testing.T) {
+	store := newQueryTestStore(t)
+
+	var seen []int
+	query := UserQuery{PageSize: 3, SortBy: SortByName}
+	for {
+		page, err := store.ListUsers(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query.PageToken == "" && page.TotalCount != 7 {
+			t.Errorf("Expected total count 7, got %d", page.TotalCount)
+		}
+		seen = append(seen, userIDs(page.Users)...)
+		if page.NextPageToken == "" {
+			break
+		}
+		query.PageToken = page.NextPageToken
+
+		// Users added between pages must not shift or repeat results
+		if _, err := store.CreateUser("Aaron", fmt.Sprintf("aaron%d@example.com", len(seen))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Seed users John Doe (1) and Jane Smith (2) sort among the test users;
+	// the Aarons sort before the cursor and never appear
+	want := []int{4, 5, 3, 6, 7, 2, 1}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, seen)
+	}
+}
+
+func TestListUsersFiltersAndSorting(t *testing.T) {
+	store := newQueryTestStore(t)
+	if _, err := store.SetUserStatus(7, UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SetUserStatus(3, UserStatusInactive); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query UserQuery
+		want  []int
+	}{
+		{"default hides inactive", UserQuery{}, []int{1, 2, 4, 5, 6, 7}},
+		{"include inactive", UserQuery{IncludeInactive: true}, []int{1, 2, 3, 4, 5, 6, 7}},
+		{"status", UserQuery{Status: UserStatusSuspended}, []int{7}},
+		{"email domain", UserQuery{EmailDomain: "@Corp.io"}, []int{4, 6}},
+		{"search", UserQuery{SearchQuery: "DAVE"}, []int{6}},
+		{"created after", UserQuery{CreatedAfter: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), SortBy: SortByCreated}, []int{6, 7, 1, 2}},
+		{"created before", UserQuery{CreatedBefore: time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC), SortBy: SortByCreated}, []int{4}},
+		{"sort desc", UserQuery{SortBy: SortByEmail, SortOrder: SortDesc, EmailDomain: "example.com"}, []int{1, 2, 7, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.ListUsers(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := userIDs(page.Users); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestListUsersRejectsBadInput(t *testing.T) {
+	store := newQueryTestStore(t)
+
+	page, err := store.ListUsers(UserQuery{PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query UserQuery
+		want  error
+	}{
+		{"negative page size", UserQuery{PageSize: -1}, ErrInvalidQuery},
+		{"unknown sort", UserQuery{SortBy: "age"}, ErrInvalidQuery},
+		{"unknown order", UserQuery{SortOrder: "sideways"}, ErrInvalidQuery},
+		{"unknown status", UserQuery{Status: "banned"}, ErrInvalidQuery},
+		{"garbage token", UserQuery{PageToken: "!!!"}, ErrInvalidPageToken},
+		{"token from another query", UserQuery{PageToken: page.NextPageToken, SortBy: SortByName}, ErrInvalidPageToken},
+	}
+	for _, tt := range tests {
+		if _, err := store.ListUsers(tt.query); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestParseUserQuery(t *testing.T) {
+	values := url.Values{
+		"page_size":      {"10"},
+		"status":         {"suspended"},
+		"created_after":  {"2025-01-02T00:00:00Z"},
+		"created_before": {"1767225600"},
+		"sort_by":        {"created"},
+		"sort_order":     {"desc"},
+	}
+	q, err := ParseUserQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.PageSize != 10 || q.Status != UserStatusSuspended || q.SortBy != SortByCreated || q.SortOrder != SortDesc {
+		t.Errorf("Unexpected query: %+v", q)
+	}
+	if !q.CreatedAfter.Equal(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)) || q.CreatedBefore.Unix() != 1767225600 {
+		t.Errorf("Unexpected time range: %v - %v", q.CreatedAfter, q.CreatedBefore)
+	}
+
+	for _, bad := range []string{"page_size=ten", "created_after=yesterday", "include_inactive=maybe"} {
+		values, _ := url.ParseQuery(bad)
+		if _, err := ParseUserQuery(values); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", bad, err)
+		}
+	}
+}